@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
-	"net/http"
-	"os"
 	"os/signal"
 	"strings"
 	"syscall"
@@ -23,16 +21,42 @@ func main() {
 	title := flag.String("title", "GoCron Scheduler", "Custom title for the UI")
 	flag.Parse()
 
+	// create the monitor which records every job run and tracks running jobs for the UI
+	monitor := server.NewMonitor()
+
 	// create the gocron scheduler
-	scheduler, err := gocron.NewScheduler()
+	scheduler, err := gocron.NewScheduler(monitor.SchedulerOptions()...)
 	if err != nil {
 		log.Fatalf("Failed to create scheduler: %v", err)
 	}
 
+	// create the API server with custom title and a task which jobs created from the UI can run
+	srv, err := server.NewServer(scheduler, *port,
+		server.WithTitle(*title),
+		server.WithMonitor(monitor),
+		server.WithSchedulerShutdown(),
+		server.WithTask("greet", func(_ context.Context, params server.Params) error {
+			for i := 0; i < params.Int("times"); i++ {
+				log.Printf("Hello, %s!", params.String("name"))
+			}
+			return nil
+		}, server.TaskSchema{
+			Description: "Logs a greeting",
+			Params: []server.TaskParam{
+				{Name: "name", Type: server.ParamString, Required: true},
+				{Name: "times", Type: server.ParamInt, Default: 1},
+			},
+		}),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	// jobs registered through the server show their exact schedule in the UI
 	// example 1: Simple interval job - runs every 10 seconds
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(10*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationJob(10*time.Second),
+		server.NewTask(func() {
 			log.Println("Running 10-second interval job")
 		}),
 		gocron.WithName("simple-10s-interval"),
@@ -43,9 +67,9 @@ func main() {
 	}
 
 	// example 2: Fast job - runs every 5 seconds
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(5*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationJob(5*time.Second),
+		server.NewTask(func() {
 			log.Println("Fast 5-second job executed")
 		}),
 		gocron.WithName("fast-5s-job"),
@@ -56,9 +80,9 @@ func main() {
 	}
 
 	// example 3: Cron job - runs every minute
-	_, err = scheduler.NewJob(
-		gocron.CronJob("* * * * *", false),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.CronJob("* * * * *", false),
+		server.NewTask(func() {
 			log.Println("Cron job executed (every minute)")
 		}),
 		gocron.WithName("cron-every-minute"),
@@ -69,11 +93,9 @@ func main() {
 	}
 
 	// example 4: Daily job at specific time
-	_, err = scheduler.NewJob(
-		gocron.DailyJob(1, gocron.NewAtTimes(
-			gocron.NewAtTime(14, 30, 0), // 2:30 PM
-		)),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DailyJob(1, "14:30"), // 2:30 PM
+		server.NewTask(func() {
 			log.Println("Daily job executed at 2:30 PM")
 		}),
 		gocron.WithName("daily-afternoon-report"),
@@ -84,10 +106,9 @@ func main() {
 	}
 
 	// example 5: Weekly job - runs on specific days
-	_, err = scheduler.NewJob(
-		gocron.WeeklyJob(1, gocron.NewWeekdays(time.Monday, time.Wednesday, time.Friday),
-			gocron.NewAtTimes(gocron.NewAtTime(9, 0, 0))),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.WeeklyJob(1, []time.Weekday{time.Monday, time.Wednesday, time.Friday}, "09:00"),
+		server.NewTask(func() {
 			log.Println("Weekly job executed (Mon, Wed, Fri at 9:00 AM)")
 		}),
 		gocron.WithName("weekly-mwf-morning"),
@@ -98,9 +119,9 @@ func main() {
 	}
 
 	// example 6: Job with parameters
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(12*time.Second),
-		gocron.NewTask(func(name string, count int) {
+	_, err = srv.NewJob(
+		server.DurationJob(12*time.Second),
+		server.NewTask(func(name string, count int) {
 			log.Printf("Job with parameters: name=%s, count=%d", name, count)
 		}, "example-job", 42),
 		gocron.WithName("parameterized-job"),
@@ -110,11 +131,19 @@ func main() {
 		log.Printf("Error creating parameterized job: %v", err)
 	}
 
-	// example 7: Job with context
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(8*time.Second),
-		gocron.NewTask(func(ctx context.Context) {
-			log.Printf("Job with context executed, context: %v", ctx)
+	// example 7: Job with context, its runs can be cancelled from the UI
+	_, err = srv.NewJob(
+		server.DurationJob(8*time.Second),
+		server.NewTask(func(ctx context.Context) error {
+			log.Println("Job with context started")
+			select {
+			case <-time.After(5 * time.Second):
+				log.Println("Job with context finished")
+				return nil
+			case <-ctx.Done():
+				log.Printf("Job with context stopped: %v", ctx.Err())
+				return ctx.Err()
+			}
 		}),
 		gocron.WithName("context-aware-job"),
 		gocron.WithTags("context", "advanced"),
@@ -124,9 +153,9 @@ func main() {
 	}
 
 	// example 8: Random duration job
-	_, err = scheduler.NewJob(
-		gocron.DurationRandomJob(5*time.Second, 15*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationRandomJob(5*time.Second, 15*time.Second),
+		server.NewTask(func() {
 			log.Println("Random interval job executed (5-15 seconds)")
 		}),
 		gocron.WithName("random-interval-job"),
@@ -137,9 +166,9 @@ func main() {
 	}
 
 	// example 9: Job with singleton mode (prevents overlapping runs)
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(5*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationJob(5*time.Second),
+		server.NewTask(func() {
 			log.Println("Singleton job started")
 			time.Sleep(8 * time.Second) // Simulate long-running task
 			log.Println("Singleton job completed")
@@ -153,9 +182,9 @@ func main() {
 	}
 
 	// example 10: Limited run job (runs only 3 times)
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(7*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationJob(7*time.Second),
+		server.NewTask(func() {
 			log.Println("Limited run job executed")
 		}),
 		gocron.WithName("limited-run-job"),
@@ -167,9 +196,9 @@ func main() {
 	}
 
 	// example 11: Job with event listeners
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(15*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationJob(15*time.Second),
+		server.NewTask(func() {
 			log.Println("Job with listeners executed")
 			// Simulate some work
 			time.Sleep(time.Duration(rand.Intn(3)+1) * time.Second)
@@ -180,8 +209,10 @@ func main() {
 			gocron.AfterJobRuns(func(_ uuid.UUID, jobName string) {
 				log.Printf("   → AfterJobRuns: %s completed", jobName)
 			}),
-			gocron.BeforeJobRuns(func(_ uuid.UUID, jobName string) {
+			gocron.BeforeJobRuns(func(id uuid.UUID, jobName string) {
 				log.Printf("   → BeforeJobRuns: %s starting", jobName)
+				// this listener replaces the monitor's global one for this job, so pass the event on
+				monitor.BeforeJobRuns(id, jobName)
 			}),
 		),
 	)
@@ -191,9 +222,9 @@ func main() {
 
 	// example 12: One-time job (runs once at a specific time)
 	oneTimeAt := time.Now().Add(30 * time.Second)
-	_, err = scheduler.NewJob(
-		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(oneTimeAt)),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.OneTimeJob(oneTimeAt),
+		server.NewTask(func() {
 			log.Println("One-time job executed!")
 		}),
 		gocron.WithName("one-time-job"),
@@ -204,9 +235,9 @@ func main() {
 	}
 
 	// example 13: Job that simulates data processing
-	_, err = scheduler.NewJob(
-		gocron.DurationJob(20*time.Second),
-		gocron.NewTask(func() {
+	_, err = srv.NewJob(
+		server.DurationJob(20*time.Second),
+		server.NewTask(func() {
 			items := rand.Intn(100) + 1
 			log.Printf("Processing %d items...", items)
 			time.Sleep(2 * time.Second)
@@ -219,7 +250,7 @@ func main() {
 		log.Printf("Error creating data processor job: %v", err)
 	}
 
-	// example 14: Health check job
+	// example 14: Health check job, registered directly on the scheduler so its schedule is inferred from its upcoming runs
 	_, err = scheduler.NewJob(
 		gocron.DurationJob(30*time.Second),
 		gocron.NewTask(func() {
@@ -240,36 +271,22 @@ func main() {
 	scheduler.Start()
 	log.Println("Scheduler started with", len(scheduler.Jobs()), "jobs")
 
-	// create and start the API server with custom title
-	srv := server.NewServer(scheduler, *port, server.WithTitle(*title))
-
-	// start server in a goroutine
-	go func() {
-		addr := fmt.Sprintf(":%d", *port)
-		log.Println("\n" + strings.Repeat("=", 70))
-		log.Printf("GoCron UI Server Started")
-		log.Println(strings.Repeat("=", 70))
-		log.Printf("Web UI:       http://localhost%s", addr)
-		log.Printf("API:          http://localhost%s/api", addr)
-		log.Printf("WebSocket:    ws://localhost%s/ws", addr)
-		log.Printf("Total Jobs:   %d", len(scheduler.Jobs()))
-		log.Println(strings.Repeat("=", 70) + "\n")
-
-		if err := http.ListenAndServe(addr, srv.Router); err != nil {
-			log.Fatalf("Server failed to start: %v", err)
-		}
-	}()
-
-	// wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// serve until an interrupt signal, then shut down the server and the scheduler gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Println("\nShutting down server...")
+	addr := fmt.Sprintf(":%d", *port)
+	log.Println("\n" + strings.Repeat("=", 70))
+	log.Printf("GoCron UI Server Started")
+	log.Println(strings.Repeat("=", 70))
+	log.Printf("Web UI:       http://localhost%s", addr)
+	log.Printf("API:          http://localhost%s/api", addr)
+	log.Printf("WebSocket:    ws://localhost%s/ws", addr)
+	log.Printf("Total Jobs:   %d", len(scheduler.Jobs()))
+	log.Println(strings.Repeat("=", 70) + "\n")
 
-	// shutdown scheduler
-	if err := scheduler.Shutdown(); err != nil {
-		log.Printf("Error shutting down scheduler: %v", err)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed: %v", err)
 	}
 
 	log.Println("Server stopped gracefully")
@@ -0,0 +1,629 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// alert conditions
+const (
+	AlertOnFailure             = "failure"              // every failed run
+	AlertOnConsecutiveFailures = "consecutive_failures" // the Count-th failed run in a row
+	AlertOnRecovery            = "recovery"             // the first successful run after at least Count failed runs
+	AlertOnMissedRun           = "missed_run"           // a scheduled run did not start within DurationSeconds
+	AlertOnDuration            = "duration"             // a run takes longer than DurationSeconds
+)
+
+var alertConditions = []string{
+	AlertOnFailure, AlertOnConsecutiveFailures, AlertOnRecovery, AlertOnMissedRun, AlertOnDuration,
+}
+
+const (
+	// DefaultMissedRunGrace is how late a run may start before missed_run rules without a duration fire
+	DefaultMissedRunGrace = time.Minute
+
+	// alertCheckInterval is how often jobs are checked for missed runs and executions which take too long
+	alertCheckInterval = 10 * time.Second
+	// alertTimeout limits how long a notifier may take to deliver an alert
+	alertTimeout = 30 * time.Second
+	// missedRunTolerance allows a run to start slightly before the time it was scheduled for
+	missedRunTolerance = time.Second
+)
+
+// alert rule sources
+const (
+	AlertRuleSourceConfig = "config" // defined with WithAlertRules, cannot be changed through the API
+	AlertRuleSourceAPI    = "api"
+)
+
+// AlertRule sends an alert through notifiers when a condition is met by a job.
+// A rule applies to the job with JobID, to the jobs with Tag, or to every job if neither is set.
+type AlertRule struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+	JobID     string `json:"jobId,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	// Count is the number of failed runs in a row for consecutive_failures and recovery, recovery defaults to 1
+	Count int `json:"count,omitempty"`
+	// DurationSeconds is the threshold of duration rules and the grace period of missed_run rules
+	DurationSeconds int64 `json:"durationSeconds,omitempty"`
+	// Notifiers names the notifiers the alert is sent through, empty means all of them
+	Notifiers []string `json:"notifiers,omitempty"`
+	Source    string   `json:"source"`
+}
+
+// Alert is a notification about a job which met the condition of a rule
+type Alert struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RuleID    string    `json:"ruleId"`
+	RuleName  string    `json:"ruleName"`
+	Condition string    `json:"condition"`
+	JobID     string    `json:"jobId"`
+	JobName   string    `json:"jobName"`
+	Tags      []string  `json:"tags"`
+	Message   string    `json:"message"`
+	Run       *JobRun   `json:"run,omitempty"` // the finished run which triggered the alert
+	// ConsecutiveFailures is the number of failed runs in a row, before the recovery for recovery alerts
+	ConsecutiveFailures int  `json:"consecutiveFailures,omitempty"`
+	Test                bool `json:"test,omitempty"` // sent through POST /api/alerts/{ruleId}/test
+}
+
+// Notifier delivers alerts, e.g. by email or to a chat
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// WithNotifier enables alerting and registers a notifier under a name, which alert rules refer to.
+// Rules are managed through /api/alerts and defined in code with WithAlertRules.
+func WithNotifier(name string, notifier Notifier) Option {
+	return func(s *Server) {
+		s.alertState().notifiers[name] = notifier
+	}
+}
+
+// WithAlertRules defines alert rules in code, rules without an ID get one derived from their position.
+// These rules are listed by the API but cannot be changed through it.
+func WithAlertRules(rules ...AlertRule) Option {
+	return func(s *Server) {
+		a := s.alertState()
+		for i, rule := range rules {
+			if rule.ID == "" {
+				rule.ID = fmt.Sprintf("config-%d", i+1)
+			}
+			rule.Source = AlertRuleSourceConfig
+			a.configRules = append(a.configRules, rule)
+		}
+	}
+}
+
+func (s *Server) alertState() *alerting {
+	if s.alerts == nil {
+		s.alerts = &alerting{
+			notifiers: make(map[string]Notifier),
+			rules:     make(map[string]AlertRule),
+			slow:      make(map[string]bool),
+			expected:  make(map[uuid.UUID]time.Time),
+			missed:    make(map[uuid.UUID]time.Time),
+		}
+	}
+	return s.alerts
+}
+
+// alerting evaluates the alert rules and sends the alerts
+type alerting struct {
+	notifiers   map[string]Notifier
+	configRules []AlertRule
+	store       Store
+
+	mutex    sync.Mutex
+	rules    map[string]AlertRule
+	slow     map[string]bool         // rule and run IDs of executions a duration alert was sent for
+	expected map[uuid.UUID]time.Time // the scheduled run of each job which was not seen to start yet
+	missed   map[uuid.UUID]time.Time // the last scheduled run of each job a missed_run alert was sent for
+}
+
+// setupAlerts validates the rules defined in code and loads the rules created through the API from the store
+func (s *Server) setupAlerts() {
+	a := s.alerts
+	for _, rule := range a.configRules {
+		if err := a.validate(rule); err != nil {
+			log.Printf("Ignoring alert rule %s: %v", rule.ID, err)
+			continue
+		}
+		a.rules[rule.ID] = rule
+	}
+
+	if s.store == nil {
+		return
+	}
+	a.store = s.store
+	values, err := s.store.List(bucketAlerts)
+	if err != nil {
+		log.Printf("Error loading alert rules from store: %v", err)
+		return
+	}
+	for key, value := range values {
+		var rule AlertRule
+		if err := json.Unmarshal(value, &rule); err != nil {
+			log.Printf("Dropping unreadable alert rule %s from store: %v", key, err)
+			_ = s.store.Delete(bucketAlerts, key)
+			continue
+		}
+		if _, ok := a.rules[rule.ID]; ok {
+			continue
+		}
+		a.rules[rule.ID] = rule
+	}
+}
+
+// validate checks a rule, notifiers have to be registered
+func (a *alerting) validate(rule AlertRule) error {
+	errs := fieldErrors{}
+	if strings.TrimSpace(rule.Name) == "" {
+		errs["name"] = "Name is required"
+	}
+	if !slices.Contains(alertConditions, rule.Condition) {
+		errs["condition"] = "Invalid condition. Supported: " + strings.Join(alertConditions, ", ")
+	}
+	if rule.JobID != "" {
+		if _, err := uuid.Parse(rule.JobID); err != nil {
+			errs["jobId"] = "Job ID must be a UUID"
+		}
+	}
+	switch {
+	case rule.Count < 0:
+		errs["count"] = "Count cannot be negative"
+	case rule.Condition == AlertOnConsecutiveFailures && rule.Count < 1:
+		errs["count"] = "Count is required for consecutive_failures"
+	}
+	switch {
+	case rule.DurationSeconds < 0:
+		errs["durationSeconds"] = "Duration cannot be negative"
+	case rule.Condition == AlertOnDuration && rule.DurationSeconds == 0:
+		errs["durationSeconds"] = "Duration is required for duration"
+	}
+	for _, name := range rule.Notifiers {
+		if _, ok := a.notifiers[name]; !ok {
+			errs["notifiers"] = fmt.Sprintf("Unknown notifier %q", name)
+		}
+	}
+	return errs.errOrNil()
+}
+
+// list returns the rules sorted by name
+func (a *alerting) list() []AlertRule {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	rules := make([]AlertRule, 0, len(a.rules))
+	for _, rule := range a.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Name != rules[j].Name {
+			return rules[i].Name < rules[j].Name
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+func (a *alerting) get(id string) (AlertRule, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	rule, ok := a.rules[id]
+	return rule, ok
+}
+
+// save adds or replaces a rule created through the API
+func (a *alerting) save(rule AlertRule) error {
+	if a.store != nil {
+		value, err := json.Marshal(rule)
+		if err != nil {
+			return err
+		}
+		if err := a.store.Put(bucketAlerts, rule.ID, value); err != nil {
+			return err
+		}
+	}
+	a.mutex.Lock()
+	a.rules[rule.ID] = rule
+	a.mutex.Unlock()
+	return nil
+}
+
+func (a *alerting) remove(id string) error {
+	if a.store != nil {
+		if err := a.store.Delete(bucketAlerts, id); err != nil {
+			return err
+		}
+	}
+	a.mutex.Lock()
+	delete(a.rules, id)
+	a.mutex.Unlock()
+	return nil
+}
+
+// matching returns the rules with a condition which apply to a job
+func (a *alerting) matching(condition, jobID string, tags []string) []AlertRule {
+	var rules []AlertRule
+	for _, rule := range a.rules {
+		if rule.Condition != condition {
+			continue
+		}
+		if rule.JobID != "" && rule.JobID != jobID {
+			continue
+		}
+		if rule.Tag != "" && !slices.Contains(tags, rule.Tag) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// observeRun evaluates the rules about finished runs, given the failures in a row of the job from before
+// and after the run, see Server.countFailure
+func (a *alerting) observeRun(run JobRun, tags []string, previous, streak int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	newAlert := func(rule AlertRule, message string) Alert {
+		return Alert{
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			Condition: rule.Condition,
+			JobID:     run.JobID,
+			JobName:   run.JobName,
+			Tags:      tags,
+			Message:   message,
+			Run:       &run,
+		}
+	}
+
+	if run.Status == RunStatusFailed {
+		for _, rule := range a.matching(AlertOnFailure, run.JobID, tags) {
+			a.send(rule, newAlert(rule, fmt.Sprintf("Job %q failed: %s", run.JobName, run.Error)))
+		}
+		for _, rule := range a.matching(AlertOnConsecutiveFailures, run.JobID, tags) {
+			if streak == rule.Count {
+				alert := newAlert(rule, fmt.Sprintf("Job %q failed %d times in a row: %s", run.JobName, streak, run.Error))
+				alert.ConsecutiveFailures = streak
+				a.send(rule, alert)
+			}
+		}
+	}
+
+	if run.Status == RunStatusSuccess && previous > 0 {
+		for _, rule := range a.matching(AlertOnRecovery, run.JobID, tags) {
+			if previous >= max(rule.Count, 1) {
+				alert := newAlert(rule, fmt.Sprintf("Job %q recovered after %d failed runs", run.JobName, previous))
+				alert.ConsecutiveFailures = previous
+				a.send(rule, alert)
+			}
+		}
+	}
+
+	took := time.Duration(run.DurationMs) * time.Millisecond
+	for _, rule := range a.matching(AlertOnDuration, run.JobID, tags) {
+		key := rule.ID + "/" + run.ID
+		if a.slow[key] {
+			// the alert was sent while the run was in progress
+			delete(a.slow, key)
+			continue
+		}
+		threshold := time.Duration(rule.DurationSeconds) * time.Second
+		if took > threshold {
+			a.send(rule, newAlert(rule, fmt.Sprintf("Job %q took %s, longer than %s", run.JobName, took.Round(time.Millisecond), threshold)))
+		}
+	}
+}
+
+// watchAlerts periodically checks the jobs for missed runs and executions which take too long
+func (s *Server) watchAlerts() {
+	ticker := time.NewTicker(alertCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.checkAlerts(now)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Server) checkAlerts(now time.Time) {
+	a := s.alerts
+
+	var running []ActiveRun
+	if s.monitor != nil {
+		running = s.monitor.Running()
+	}
+	var jobs []jobState
+	if !s.schedulerStopped.Load() {
+		for _, job := range s.Scheduler.Jobs() {
+			state := jobState{id: job.ID(), name: job.Name(), tags: job.Tags()}
+			state.nextRun, _ = job.NextRun()
+			state.lastRun, _ = job.LastRun()
+			jobs = append(jobs, state)
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, run := range running {
+		id, err := uuid.Parse(run.JobID)
+		if err != nil {
+			continue
+		}
+		tags, _ := s.jobTags(id)
+		elapsed := now.Sub(run.StartedAt)
+		for _, rule := range a.matching(AlertOnDuration, run.JobID, tags) {
+			key := rule.ID + "/" + run.ID
+			threshold := time.Duration(rule.DurationSeconds) * time.Second
+			if a.slow[key] || elapsed <= threshold {
+				continue
+			}
+			a.slow[key] = true
+			a.send(rule, Alert{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				Condition: rule.Condition,
+				JobID:     run.JobID,
+				JobName:   run.JobName,
+				Tags:      tags,
+				Message: fmt.Sprintf("Job %q is running for %s, longer than %s",
+					run.JobName, elapsed.Round(time.Second), threshold),
+			})
+		}
+	}
+
+	// a run is missed when the time it was scheduled for passed by the grace period and the job did not
+	// start since, the expected run is only replaced by the next one once it started or was reported
+	seen := make(map[uuid.UUID]bool, len(jobs))
+	for _, job := range jobs {
+		seen[job.id] = true
+		expected, ok := a.expected[job.id]
+		if !ok || expected.IsZero() || now.Before(expected) || !job.lastRun.Before(expected.Add(-missedRunTolerance)) {
+			a.expected[job.id] = job.nextRun
+			continue
+		}
+
+		rules := a.matching(AlertOnMissedRun, job.id.String(), job.tags)
+		if len(rules) == 0 || a.missed[job.id].Equal(expected) {
+			a.expected[job.id] = job.nextRun
+			continue
+		}
+		late := now.Sub(expected)
+		reported := false
+		for _, rule := range rules {
+			grace := DefaultMissedRunGrace
+			if rule.DurationSeconds > 0 {
+				grace = time.Duration(rule.DurationSeconds) * time.Second
+			}
+			if late <= grace {
+				continue
+			}
+			reported = true
+			a.send(rule, Alert{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				Condition: rule.Condition,
+				JobID:     job.id.String(),
+				JobName:   job.name,
+				Tags:      job.tags,
+				Message: fmt.Sprintf("Job %q did not run at %s, it is %s late",
+					job.name, expected.Format(time.RFC3339), late.Round(time.Second)),
+			})
+		}
+		if reported {
+			// the next run may still be the missed one if the scheduler is stuck
+			a.missed[job.id] = expected
+			a.expected[job.id] = job.nextRun
+		}
+	}
+	// jobs which were removed, paused or are not checked while the scheduler is stopped
+	for id := range a.expected {
+		if !seen[id] {
+			delete(a.expected, id)
+			delete(a.missed, id)
+		}
+	}
+}
+
+// jobState is what the missed run check needs to know about a scheduled job
+type jobState struct {
+	id      uuid.UUID
+	name    string
+	tags    []string
+	nextRun time.Time
+	lastRun time.Time
+}
+
+// send delivers an alert through the notifiers of a rule in the background
+func (a *alerting) send(rule AlertRule, alert Alert) {
+	alert.ID = uuid.NewString()
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+
+	names := rule.Notifiers
+	if len(names) == 0 {
+		for name := range a.notifiers {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		notifier, ok := a.notifiers[name]
+		if !ok {
+			continue
+		}
+		go func() {
+			if err := notify(notifier, alert); err != nil {
+				log.Printf("Error sending alert of rule %s for job %s through %s: %v", rule.ID, alert.JobID, name, err)
+			}
+		}()
+	}
+}
+
+func notify(notifier Notifier, alert Alert) error {
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+	return notifier.Notify(ctx, alert)
+}
+
+// AlertsResponse lists the alert rules and the notifiers they can use
+type AlertsResponse struct {
+	Rules     []AlertRule `json:"rules"`
+	Notifiers []string    `json:"notifiers"`
+}
+
+// GetAlerts lists the alert rules
+func (s *Server) GetAlerts(w http.ResponseWriter, _ *http.Request) {
+	notifiers := make([]string, 0, len(s.alerts.notifiers))
+	for name := range s.alerts.notifiers {
+		notifiers = append(notifiers, name)
+	}
+	sort.Strings(notifiers)
+
+	respondJSON(w, http.StatusOK, AlertsResponse{
+		Rules:     s.alerts.list(),
+		Notifiers: notifiers,
+	})
+}
+
+// CreateAlert adds an alert rule
+func (s *Server) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	var rule AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rule.ID = uuid.NewString()
+	rule.Source = AlertRuleSourceAPI
+
+	if err := s.alerts.validate(rule); err != nil {
+		respondJobError(w, err)
+		return
+	}
+	if err := s.alerts.save(rule); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+// UpdateAlert replaces an alert rule which was created through the API
+func (s *Server) UpdateAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["ruleId"]
+	if !s.changeableAlert(w, id) {
+		return
+	}
+
+	var rule AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rule.ID = id
+	rule.Source = AlertRuleSourceAPI
+
+	if err := s.alerts.validate(rule); err != nil {
+		respondJobError(w, err)
+		return
+	}
+	if err := s.alerts.save(rule); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// DeleteAlert removes an alert rule which was created through the API
+func (s *Server) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["ruleId"]
+	if !s.changeableAlert(w, id) {
+		return
+	}
+	if err := s.alerts.remove(id); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Alert rule deleted"})
+}
+
+// changeableAlert checks that a rule exists and was not defined in code.
+// It writes the error response and returns false if not.
+func (s *Server) changeableAlert(w http.ResponseWriter, id string) bool {
+	rule, ok := s.alerts.get(id)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Alert rule not found")
+		return false
+	}
+	if rule.Source == AlertRuleSourceConfig {
+		respondError(w, http.StatusConflict, "Alert rule is defined in code and cannot be changed")
+		return false
+	}
+	return true
+}
+
+// TestAlert sends a test alert through the notifiers of a rule and reports how each of them did
+func (s *Server) TestAlert(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.alerts.get(mux.Vars(r)["ruleId"])
+	if !ok {
+		respondError(w, http.StatusNotFound, "Alert rule not found")
+		return
+	}
+
+	alert := Alert{
+		ID:        uuid.NewString(),
+		Time:      time.Now(),
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		Condition: rule.Condition,
+		JobID:     rule.JobID,
+		Message:   fmt.Sprintf("Test alert of rule %q", rule.Name),
+		Test:      true,
+	}
+	if rule.Tag != "" {
+		alert.Tags = []string{rule.Tag}
+	}
+
+	names := rule.Notifiers
+	if len(names) == 0 {
+		for name := range s.alerts.notifiers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	results := make(map[string]string, len(names))
+	for _, name := range names {
+		notifier, ok := s.alerts.notifiers[name]
+		if !ok {
+			results[name] = "unknown notifier"
+			continue
+		}
+		if err := notify(notifier, alert); err != nil {
+			results[name] = err.Error()
+			continue
+		}
+		results[name] = "sent"
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
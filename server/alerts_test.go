@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+// notifierFunc adapts a function to a Notifier
+type notifierFunc func(ctx context.Context, alert Alert) error
+
+func (f notifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+func TestAlertStreakSurvivesRestart(t *testing.T) {
+	jobID := uuid.New()
+	history := NewMemoryHistoryStore(0)
+	// the runs before the restart, the failures in a row come after the last success
+	start := time.Now().Add(-time.Hour)
+	for i, status := range []string{RunStatusFailed, RunStatusSuccess, RunStatusFailed, RunStatusCancelled, RunStatusFailed} {
+		run := JobRun{
+			ID:        uuid.NewString(),
+			JobID:     jobID.String(),
+			JobName:   "cleanup",
+			StartedAt: start.Add(time.Duration(i) * time.Minute),
+			Status:    status,
+		}
+		if err := history.Add(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts := make(chan Alert, 4)
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(scheduler, 0,
+		WithMonitor(NewMonitor()),
+		WithHistoryStore(history),
+		WithNotifier("test", notifierFunc(func(_ context.Context, alert Alert) error {
+			alerts <- alert
+			return nil
+		})),
+		WithAlertRules(
+			AlertRule{Name: "three failures", Condition: AlertOnConsecutiveFailures, Count: 3},
+			AlertRule{Name: "recovered", Condition: AlertOnRecovery, Count: 3},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	receive := func() Alert {
+		t.Helper()
+		select {
+		case alert := <-alerts:
+			return alert
+		case <-time.After(5 * time.Second):
+			t.Fatal("no alert sent")
+			return Alert{}
+		}
+	}
+	finish := func(err error) {
+		now := time.Now()
+		s.recordRun(runRecord{jobID: jobID, jobName: "cleanup", startedAt: now, endedAt: now, err: err})
+	}
+
+	finish(errors.New("disk full"))
+	alert := receive()
+	if alert.Condition != AlertOnConsecutiveFailures || alert.ConsecutiveFailures != 3 {
+		t.Fatalf("alert = %+v, want the third failure in a row", alert)
+	}
+	if got := s.consecutiveFailures(jobID.String()); got != 3 {
+		t.Errorf("consecutiveFailures = %d, want 3", got)
+	}
+
+	finish(nil)
+	alert = receive()
+	if alert.Condition != AlertOnRecovery || alert.ConsecutiveFailures != 3 {
+		t.Fatalf("alert = %+v, want the recovery after 3 failures", alert)
+	}
+	if got := s.consecutiveFailures(jobID.String()); got != 0 {
+		t.Errorf("consecutiveFailures = %d after a success", got)
+	}
+
+	select {
+	case alert := <-alerts:
+		t.Errorf("unexpected alert %+v", alert)
+	default:
+	}
+}
@@ -0,0 +1,518 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DefaultMaxAuditEntries is the number of entries kept by the in-memory audit log
+const DefaultMaxAuditEntries = 10000
+
+// audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+	AuditDenied  = "denied"
+)
+
+// audited actions
+const (
+	ActionJobCreate      = "job.create"
+	ActionJobUpdate      = "job.update"
+	ActionJobDelete      = "job.delete"
+	ActionJobRun         = "job.run"
+	ActionJobPause       = "job.pause"
+	ActionJobResume      = "job.resume"
+	ActionRunCancel      = "run.cancel"
+	ActionSchedulerStop  = "scheduler.stop"
+	ActionSchedulerStart = "scheduler.start"
+	ActionAlertCreate    = "alert.create"
+	ActionAlertUpdate    = "alert.update"
+	ActionAlertDelete    = "alert.delete"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+
+	// maxAuditResponse is how much of a response is kept to find the ID of a created job or the error message
+	maxAuditResponse = 64 << 10
+)
+
+// AuditFilter selects audit entries, zero fields match everything
+type AuditFilter struct {
+	Actor string
+	Job   string // ID or name of the job
+	Since time.Time
+	Until time.Time
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Job != "" && e.JobID != f.Job && e.JobName != f.Job {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditLog stores audit entries
+type AuditLog interface {
+	// Record adds an entry
+	Record(entry AuditEntry) error
+	// Query returns the entries matching the filter newest first, skipping offset entries and returning at most
+	// limit entries, or all of them if limit is zero, together with the total number of matching entries
+	Query(filter AuditFilter, offset, limit int) ([]AuditEntry, int, error)
+}
+
+// MemoryAuditLog is a bounded in-memory AuditLog which keeps the most recent entries.
+// When created by the server for WithStore it also writes every entry through to the store.
+type MemoryAuditLog struct {
+	mu         sync.RWMutex
+	entries    []AuditEntry // oldest first
+	maxEntries int
+	store      Store
+}
+
+// NewMemoryAuditLog creates an in-memory audit log keeping at most maxEntries entries.
+// A non-positive value falls back to DefaultMaxAuditEntries.
+func NewMemoryAuditLog(maxEntries int) *MemoryAuditLog {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxAuditEntries
+	}
+	return &MemoryAuditLog{maxEntries: maxEntries}
+}
+
+// newPersistentAuditLog loads the audit log from a store and writes every entry through to it
+func newPersistentAuditLog(store Store) (*MemoryAuditLog, error) {
+	a := NewMemoryAuditLog(0)
+
+	values, err := store.List(bucketAudit)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		var entry AuditEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			log.Printf("Dropping unreadable audit entry %s from store: %v", key, err)
+			_ = store.Delete(bucketAudit, key)
+			continue
+		}
+		a.entries = append(a.entries, entry)
+	}
+	sort.SliceStable(a.entries, func(i, j int) bool {
+		return a.entries[i].Time.Before(a.entries[j].Time)
+	})
+
+	a.store = store
+	a.prune()
+	return a, nil
+}
+
+// Record adds an entry, evicting the oldest entries beyond the maximum
+func (a *MemoryAuditLog) Record(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.store != nil {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := a.store.Put(bucketAudit, entry.ID, value); err != nil {
+			return err
+		}
+	}
+
+	a.entries = append(a.entries, entry)
+	a.prune()
+	return nil
+}
+
+// prune drops the oldest entries beyond the maximum, a.mu must be held
+func (a *MemoryAuditLog) prune() {
+	drop := len(a.entries) - a.maxEntries
+	if drop <= 0 {
+		return
+	}
+
+	if a.store != nil {
+		for _, entry := range a.entries[:drop] {
+			if err := a.store.Delete(bucketAudit, entry.ID); err != nil {
+				log.Printf("Error deleting audit entry %s from store: %v", entry.ID, err)
+			}
+		}
+	}
+	a.entries = append([]AuditEntry(nil), a.entries[drop:]...)
+}
+
+// Query returns the matching entries newest first
+func (a *MemoryAuditLog) Query(filter AuditFilter, offset, limit int) ([]AuditEntry, int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]AuditEntry, 0)
+	total := 0
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		if !filter.matches(a.entries[i]) {
+			continue
+		}
+		total++
+		if total > offset && (limit == 0 || len(result) < limit) {
+			result = append(result, a.entries[i])
+		}
+	}
+	return result, total, nil
+}
+
+// WithAuditLog sets where the calls of mutating endpoints are recorded, by default the most recent entries
+// are kept in memory, and in the store if WithStore is used
+func WithAuditLog(audit AuditLog) Option {
+	return func(s *Server) {
+		s.audit = audit
+	}
+}
+
+// newAuditLog creates the default audit log, persisted when a store is configured
+func (s *Server) newAuditLog() AuditLog {
+	if s.store != nil {
+		audit, err := newPersistentAuditLog(s.store)
+		if err == nil {
+			return audit
+		}
+		log.Printf("Error loading audit log from store, keeping it in memory only: %v", err)
+	}
+	return NewMemoryAuditLog(0)
+}
+
+// auditRecorder captures the status and the beginning of a response for the audit log
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *auditRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *auditRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if room := maxAuditResponse - rec.body.Len(); room > 0 {
+		rec.body.Write(b[:min(len(b), room)])
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// auditedHandler is the handler of a mutating route, recognized by auditUnauthenticated
+type auditedHandler struct {
+	s      *Server
+	action string
+	next   http.HandlerFunc
+}
+
+// audited wraps the handler of a mutating route so that every call is recorded in the audit log,
+// including calls which were denied or failed. The job's definition is captured before and after the call
+// to record what changed.
+func (s *Server) audited(action string, next http.HandlerFunc) http.Handler {
+	return auditedHandler{s: s, action: action, next: next}
+}
+
+func (h auditedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.s
+	entry, id, hasID := s.newAuditEntry(h.action, r)
+	var before map[string]any
+	if hasID {
+		before, entry.JobName, _ = s.jobSnapshot(id)
+	}
+
+	rec := &auditRecorder{ResponseWriter: w}
+	h.next(rec, r)
+	response := rec.finish(&entry)
+
+	// a created job is only known by the ID in the response
+	if !hasID && h.action == ActionJobCreate && entry.Outcome == AuditSuccess {
+		if parsed, err := uuid.Parse(response.ID); err == nil {
+			id, hasID = parsed, true
+			entry.JobID = id.String()
+		}
+	}
+	if h.action == ActionAlertCreate && entry.Outcome == AuditSuccess {
+		entry.RuleID = response.ID
+	}
+	if hasID && entry.Outcome == AuditSuccess {
+		after, name, _ := s.jobSnapshot(id)
+		if name != "" {
+			entry.JobName = name
+		}
+		entry.Changes = diffSnapshots(before, after)
+	}
+
+	s.recordAudit(entry)
+}
+
+// auditUnauthenticated records the calls of mutating routes which requireAuth rejects for missing or invalid
+// credentials, since they never reach the audited handler. It must run before requireAuth.
+func (s *Server) auditUnauthenticated(next http.Handler) http.Handler {
+	if len(s.authenticators) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := auditedRoute(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &auditRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == http.StatusUnauthorized {
+			s.auditRejected(h.action, r, rec)
+		}
+	})
+}
+
+// auditedRoute returns the handler of the mutating route a request matched, if it did
+func auditedRoute(r *http.Request) (auditedHandler, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return auditedHandler{}, false
+	}
+	h, ok := route.GetHandler().(auditedHandler)
+	return h, ok
+}
+
+// auditRejected records a call which a middleware answered before it reached the audited handler
+func (s *Server) auditRejected(action string, r *http.Request, rec *auditRecorder) {
+	entry, id, hasID := s.newAuditEntry(action, r)
+	if hasID {
+		_, entry.JobName, _ = s.jobSnapshot(id)
+	}
+	rec.finish(&entry)
+	s.recordAudit(entry)
+}
+
+// newAuditEntry starts the audit entry of a call, returning the ID of the job it is about if there is one
+func (s *Server) newAuditEntry(action string, r *http.Request) (AuditEntry, uuid.UUID, bool) {
+	entry := AuditEntry{
+		ID:     uuid.NewString(),
+		Time:   time.Now(),
+		IP:     s.remoteIP(r),
+		Action: action,
+	}
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		entry.Actor = p.Name
+	}
+	entry.Endpoint = r.Method + " " + r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			entry.Endpoint = r.Method + " " + tmpl
+		}
+	}
+
+	entry.RuleID = mux.Vars(r)["ruleId"]
+	if idStr, ok := mux.Vars(r)["id"]; ok {
+		if id, err := uuid.Parse(idStr); err == nil {
+			entry.JobID = id.String()
+			return entry, id, true
+		}
+	}
+	return entry, uuid.Nil, false
+}
+
+// auditResponse holds the fields of a response which end up in the audit log
+type auditResponse struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// finish fills in the status, the outcome and the error of an audit entry from the recorded response
+func (rec *auditRecorder) finish(entry *AuditEntry) auditResponse {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	entry.Status = rec.status
+	switch {
+	case rec.status == http.StatusUnauthorized || rec.status == http.StatusForbidden:
+		entry.Outcome = AuditDenied
+	case rec.status >= 400:
+		entry.Outcome = AuditFailure
+	default:
+		entry.Outcome = AuditSuccess
+	}
+
+	var response auditResponse
+	_ = json.Unmarshal(rec.body.Bytes(), &response)
+	entry.Error = response.Error
+	return response
+}
+
+func (s *Server) recordAudit(entry AuditEntry) {
+	if err := s.audit.Record(entry); err != nil {
+		log.Printf("Error recording audit entry for %s: %v", entry.Endpoint, err)
+	}
+}
+
+// jobSnapshot captures the definition of a job as it is returned by the API, so that it can be compared
+func (s *Server) jobSnapshot(id uuid.UUID) (map[string]any, string, bool) {
+	var snapshot any
+	var name string
+	if mj, ok := s.managedJob(id); ok {
+		name = mj.name
+		if mj.request != nil {
+			snapshot = mj.currentRequest()
+		} else {
+			snapshot = struct {
+				Name     string       `json:"name"`
+				Tags     []string     `json:"tags"`
+				Schedule ScheduleSpec `json:"schedule"`
+			}{mj.name, mj.tags, mj.spec}
+		}
+	} else if job, ok := s.findJob(id); ok {
+		name = job.Name()
+		snapshot = struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}{job.Name(), job.Tags()}
+	} else {
+		return nil, "", false
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, name, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, name, false
+	}
+
+	mj, _ := s.managedJob(id)
+	fields["paused"] = mj != nil && mj.paused != nil
+	return fields, name, true
+}
+
+// diffSnapshots lists the fields which differ between two snapshots of a job, sorted by field
+func diffSnapshots(before, after map[string]any) []AuditChange {
+	fields := make(map[string]bool, len(before)+len(after))
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	var changes []AuditChange
+	for field := range fields {
+		b, a := before[field], after[field]
+		if !reflect.DeepEqual(b, a) {
+			changes = append(changes, AuditChange{Field: field, Before: b, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// remoteIP returns the IP address the request came from. With WithForwardedHeaders it is the client's
+// address from the X-Forwarded-For header of the proxy.
+func (s *Server) remoteIP(r *http.Request) string {
+	if s.trustForwarded {
+		if ip := firstForwarded(r.Header.Get("X-Forwarded-For")); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// GetAudit lists audit entries newest first, filtered by actor, job (ID or name), since and until (RFC3339).
+// With format=jsonl or an Accept header of application/x-ndjson all matching entries are exported as JSON lines.
+func (s *Server) GetAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := AuditFilter{
+		Actor: query.Get("actor"),
+		Job:   query.Get("job"),
+	}
+	for key, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(key); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s, use RFC3339", key))
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	if query.Get("format") == "jsonl" || r.Header.Get("Accept") == "application/x-ndjson" {
+		entries, _, err := s.audit.Query(filter, 0, 0)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="gocron-ui-audit.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				log.Printf("Error exporting audit log: %v", err)
+				return
+			}
+		}
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultAuditPageSize)
+	if err != nil || limit <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		respondError(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+
+	entries, total, err := s.audit.Query(filter, offset, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, AuditResponse{
+		Entries: entries,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
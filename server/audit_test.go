@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func TestRemoteIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded bool
+		header    string
+		want      string
+	}{
+		{name: "direct", want: "10.0.0.2"},
+		{name: "header not trusted", header: "203.0.113.7", want: "10.0.0.2"},
+		{name: "forwarded", forwarded: true, header: "203.0.113.7", want: "203.0.113.7"},
+		{name: "chain of proxies", forwarded: true, header: "203.0.113.7, 10.0.0.9", want: "203.0.113.7"},
+		{name: "ipv6", forwarded: true, header: "2001:db8::1", want: "2001:db8::1"},
+		{name: "no header", forwarded: true, want: "10.0.0.2"},
+		{name: "not an address", forwarded: true, header: "unknown", want: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustForwarded: tt.forwarded}
+			r := httptest.NewRequest(http.MethodPost, "/api/jobs", nil)
+			r.RemoteAddr = "10.0.0.2:51234"
+			if tt.header != "" {
+				r.Header.Set("X-Forwarded-For", tt.header)
+			}
+			if got := s.remoteIP(r); got != tt.want {
+				t.Errorf("remoteIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditReadOnlyWrites(t *testing.T) {
+	s, _ := newMonitoredServer(t, WithReadOnly(), WithForwardedHeaders())
+	job, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/api/jobs/" + job.ID().String() + "/run", "/api/jobs"} {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set("X-Forwarded-For", "203.0.113.7")
+		rec := httptest.NewRecorder()
+		s.Router.ServeHTTP(rec, r)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("POST %s = %d, want %d", path, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	entries, total, err := s.audit.Query(AuditFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Fatalf("%d audit entries, want the 2 rejected writes: %+v", total, entries)
+	}
+	for _, entry := range entries {
+		if entry.Outcome != AuditDenied || entry.Status != http.StatusForbidden || entry.Error != "The server is read-only" {
+			t.Errorf("entry %s = %s %d %q, want denied", entry.Endpoint, entry.Outcome, entry.Status, entry.Error)
+		}
+		if entry.IP != "203.0.113.7" {
+			t.Errorf("entry %s has IP %q, want the forwarded one", entry.Endpoint, entry.IP)
+		}
+	}
+	// newest first
+	if entries[1].Action != ActionJobRun || entries[1].JobName != "report" || entries[0].Action != ActionJobCreate {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	// reads are not audited
+	serve(s, http.MethodGet, "/api/jobs", "")
+	if _, total, _ := s.audit.Query(AuditFilter{}, 0, 10); total != 2 {
+		t.Errorf("%d audit entries after a read", total)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	// Authenticate returns the caller of a request. It returns nil and no error if the request carries no
+	// credentials this authenticator understands, and an error if it carries credentials which are invalid.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Challenger is implemented by authenticators which tell clients how to authenticate on a 401 response,
+// e.g. with a WWW-Authenticate header
+type Challenger interface {
+	Challenge(w http.ResponseWriter, r *http.Request)
+}
+
+// RouteAuthenticator is implemented by authenticators which need their own routes, such as a login form or
+// an OIDC callback. The routes are registered on a router for /auth and are reachable without authentication.
+type RouteAuthenticator interface {
+	Authenticator
+	RegisterRoutes(router *mux.Router)
+}
+
+// LoginMethod is a way to log in which the login page offers
+type LoginMethod struct {
+	Type  string `json:"type"` // password: the page posts username and password to URL, redirect: the page sends the browser to URL
+	Label string `json:"label"`
+	URL   string `json:"url"` // a path such as /auth/login is below the base path of the server
+}
+
+// LoginProvider is implemented by authenticators which offer a login on the login page
+type LoginProvider interface {
+	LoginMethods() []LoginMethod
+}
+
+// login method types
+const (
+	LoginPassword = "password"
+	LoginRedirect = "redirect"
+)
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the authenticated caller stored in the context of a request
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+func contextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+type basePathKey struct{}
+
+// BasePathFromContext returns the base path the server is mounted under, see WithBasePath. Authenticators use it
+// for the paths of their cookies and redirects.
+func BasePathFromContext(ctx context.Context) string {
+	path, _ := ctx.Value(basePathKey{}).(string)
+	return path
+}
+
+// cookiePath returns the path the server's cookies are scoped to, the base path or the root
+func cookiePath(r *http.Request) string {
+	if path := BasePathFromContext(r.Context()); path != "" {
+		return path
+	}
+	return "/"
+}
+
+// storeBasePath is a mux middleware which stores the base path in the context of every request
+func (s *Server) storeBasePath(next http.Handler) http.Handler {
+	if s.basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), basePathKey{}, s.basePath)))
+	})
+}
+
+// routePath is the path of a request below the base path
+func (s *Server) routePath(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, s.basePath)
+}
+
+// WithAuthenticator requires every request to be authenticated, except for the login page and the routes under /auth.
+// Several authenticators can be given, the first one which recognizes the credentials of a request decides.
+func WithAuthenticator(authenticators ...Authenticator) Option {
+	return func(s *Server) {
+		s.authenticators = append(s.authenticators, authenticators...)
+	}
+}
+
+// publicPaths can be requested without authentication so that the login page works
+var publicPaths = map[string]bool{
+	"/login.html": true,
+	"/login.js":   true,
+	"/style.css":  true,
+}
+
+// isPublic reports whether a request is allowed without authentication
+func (s *Server) isPublic(r *http.Request) bool {
+	path := s.routePath(r)
+	if publicPaths[path] || strings.HasPrefix(path, "/auth/") {
+		return true
+	}
+	return s.metrics != nil && s.metrics.cfg.Public && path == s.metrics.cfg.Path
+}
+
+// authenticate returns the caller of a request, or nil if no authenticator recognized its credentials
+func (s *Server) authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range s.authenticators {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// requireAuth rejects requests without valid credentials and stores the caller in the context of the others
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	if len(s.authenticators) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isPublic(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := s.authenticate(r)
+		if err != nil || p == nil {
+			s.unauthorized(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(contextWithPrincipal(r.Context(), p)))
+	})
+}
+
+// unauthorized sends browsers which navigate to the UI to the login page and answers everything else with a 401 error
+func (s *Server) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	path := s.routePath(r)
+	isPage := r.Method == http.MethodGet && (path == "/" || strings.HasSuffix(path, ".html"))
+	if isPage && len(s.loginMethods()) > 0 {
+		http.Redirect(w, r, s.basePath+"/login.html", http.StatusFound)
+		return
+	}
+
+	for _, a := range s.authenticators {
+		if c, ok := a.(Challenger); ok {
+			c.Challenge(w, r)
+		}
+	}
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	respondError(w, http.StatusUnauthorized, "Authentication required")
+}
+
+func (s *Server) loginMethods() []LoginMethod {
+	methods := make([]LoginMethod, 0)
+	for _, a := range s.authenticators {
+		if l, ok := a.(LoginProvider); ok {
+			for _, method := range l.LoginMethods() {
+				if strings.HasPrefix(method.URL, "/") {
+					method.URL = s.basePath + method.URL
+				}
+				methods = append(methods, method)
+			}
+		}
+	}
+	return methods
+}
+
+// registerAuthRoutes adds the routes of the authenticators and the endpoints the login page uses
+func (s *Server) registerAuthRoutes(router *mux.Router) {
+	auth := router.PathPrefix("/auth").Subrouter()
+	auth.HandleFunc("/methods", s.GetLoginMethods).Methods("GET")
+	auth.HandleFunc("/me", s.GetCurrentUser).Methods("GET")
+	for _, a := range s.authenticators {
+		if ra, ok := a.(RouteAuthenticator); ok {
+			ra.RegisterRoutes(auth)
+		}
+	}
+}
+
+// GetLoginMethods lists the ways to log in which the login page offers
+func (s *Server) GetLoginMethods(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.loginMethods())
+}
+
+// GetCurrentUser gets the authenticated caller
+func (s *Server) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	if len(s.authenticators) == 0 {
+		respondError(w, http.StatusNotFound, "Authentication is not enabled")
+		return
+	}
+
+	// the /auth routes are public, so the caller is looked up here
+	p, err := s.authenticate(r)
+	if err != nil || p == nil {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	respondJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidCredentials is returned by authenticators for credentials which are present but wrong
+var ErrInvalidCredentials = errors.New("gocron-ui: invalid credentials")
+
+// PasswordChecker checks a username and password and returns the user they belong to
+type PasswordChecker interface {
+	// CheckPassword returns the user, or ErrInvalidCredentials if the username or password is wrong
+	CheckPassword(username, password string) (*Principal, error)
+}
+
+// User is a user with a password, see Users
+type User struct {
+	Name     string
+	Password string
+	Roles    []string
+}
+
+// Users is a PasswordChecker for a fixed list of users, passwords are compared in constant time
+type Users []User
+
+var _ PasswordChecker = Users(nil)
+
+// CheckPassword returns the user with the username if the password matches
+func (u Users) CheckPassword(username, password string) (*Principal, error) {
+	given := sha256.Sum256([]byte(password))
+	for _, user := range u {
+		if user.Name != username {
+			continue
+		}
+		// comparing hashes keeps the comparison independent of the password's length
+		want := sha256.Sum256([]byte(user.Password))
+		if subtle.ConstantTimeCompare(given[:], want[:]) == 1 {
+			return &Principal{Name: user.Name, Roles: user.Roles}, nil
+		}
+		return nil, ErrInvalidCredentials
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// BasicAuthenticator authenticates requests with HTTP Basic credentials.
+// Browsers ask for the credentials themselves, so it needs no login page.
+type BasicAuthenticator struct {
+	realm     string
+	passwords PasswordChecker
+}
+
+var (
+	_ Authenticator = (*BasicAuthenticator)(nil)
+	_ Challenger    = (*BasicAuthenticator)(nil)
+)
+
+// NewBasicAuthenticator creates an authenticator which checks HTTP Basic credentials with passwords
+func NewBasicAuthenticator(realm string, passwords PasswordChecker) *BasicAuthenticator {
+	if realm == "" {
+		realm = "GoCron UI"
+	}
+	return &BasicAuthenticator{realm: realm, passwords: passwords}
+}
+
+// Authenticate checks the Basic credentials of a request
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	return a.passwords.CheckPassword(username, password)
+}
+
+// Challenge asks the client for Basic credentials
+func (a *BasicAuthenticator) Challenge(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", a.realm))
+}
+
+// TokenAuthenticator authenticates requests with static bearer tokens in the Authorization header.
+// Browsers cannot set headers on WebSocket connections, so WebSocket upgrades may pass the token
+// in the access_token query parameter instead.
+type TokenAuthenticator struct {
+	tokens map[[sha256.Size]byte]Principal
+}
+
+var (
+	_ Authenticator = (*TokenAuthenticator)(nil)
+	_ Challenger    = (*TokenAuthenticator)(nil)
+)
+
+// NewTokenAuthenticator creates an authenticator for the given tokens and the callers they identify
+func NewTokenAuthenticator(tokens map[string]Principal) *TokenAuthenticator {
+	a := &TokenAuthenticator{tokens: make(map[[sha256.Size]byte]Principal, len(tokens))}
+	for token, p := range tokens {
+		// only hashes are kept, so that looking a token up does not compare the secret itself
+		a.tokens[sha256.Sum256([]byte(token))] = p
+	}
+	return a
+}
+
+// Authenticate checks the bearer token of a request
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := ""
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, value, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return nil, nil
+		}
+		token = strings.TrimSpace(value)
+	} else if isStreaming(r) {
+		// browsers cannot set headers on WebSockets and event streams
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return nil, nil
+	}
+
+	p, ok := a.tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &p, nil
+}
+
+// Challenge tells the client to send a bearer token
+func (a *TokenAuthenticator) Challenge(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Add("WWW-Authenticate", `Bearer realm="GoCron UI"`)
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func isEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// isStreaming reports whether a request opens a long-lived WebSocket or event stream
+func isStreaming(r *http.Request) bool {
+	return isWebSocketUpgrade(r) || isEventStream(r)
+}
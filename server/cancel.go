@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// contextType is the type of context.Context, tasks which take it as their first parameter can be cancelled
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// jobRef holds the ID of a job once it is known, so that its task can tell which job it runs for
+type jobRef struct {
+	id    atomic.Pointer[uuid.UUID]
+	ready chan struct{} // closed by release, nil for a job which is registered already
+}
+
+func newJobRef(id uuid.UUID) *jobRef {
+	ref := &jobRef{}
+	if id != uuid.Nil {
+		ref.set(id)
+	}
+	return ref
+}
+
+// newPendingJobRef creates the reference of a job which is being registered. Runs which start before
+// release was called wait for it, so that they find the job's definition.
+func newPendingJobRef(id uuid.UUID) *jobRef {
+	ref := newJobRef(id)
+	ref.ready = make(chan struct{})
+	return ref
+}
+
+func (r *jobRef) release() {
+	close(r.ready)
+}
+
+func (r *jobRef) set(id uuid.UUID) {
+	r.id.Store(&id)
+}
+
+func (r *jobRef) get() (uuid.UUID, bool) {
+	if r.ready != nil {
+		<-r.ready
+	}
+	id := r.id.Load()
+	if id == nil {
+		return uuid.Nil, false
+	}
+	return *id, true
+}
+
+// acceptsContext reports whether the task's function takes a context.Context as its first parameter
+func (t Task) acceptsContext() bool {
+	fn := reflect.TypeOf(t.function)
+	return fn != nil && fn.Kind() == reflect.Func && fn.NumIn() > 0 && fn.In(0) == contextType
+}
+
+// gocronTask creates the gocron task of a job. Functions which take a context.Context as their first parameter
+// get one which is cancelled when their execution is cancelled through the API, in addition to when gocron cancels it.
+// With a tracer every call of the function runs in a span, see WithTracerProvider. Every call is counted, so that
+// a job which is registered again only gets the runs it has left. With a monitor every call reports that the job
+// started running, so that only the runs gocron really starts are listed as running.
+func (s *Server) gocronTask(t Task, ref *jobRef) gocron.Task {
+	fnType := reflect.TypeOf(t.function)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		// gocron rejects the task
+		return t.gocronTask()
+	}
+
+	fn := reflect.ValueOf(t.function)
+	if s.monitor != nil && t.acceptsContext() {
+		fn = s.cancellableTask(fn, ref)
+	}
+	if s.tracer != nil {
+		fn = s.traceTask(fn, ref)
+	}
+	fn = s.countedTask(fn, ref)
+	if s.monitor != nil {
+		fn = s.trackedTask(fn, ref)
+	}
+	return gocron.NewTask(fn.Interface(), t.parameters...)
+}
+
+// trackedTask reports the start of every call of a job's function to the monitor
+func (s *Server) trackedTask(fn reflect.Value, ref *jobRef) reflect.Value {
+	return reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		if id, ok := ref.get(); ok {
+			s.monitor.taskStarted(id, s.jobName(id))
+		}
+		return call(fn, args)
+	})
+}
+
+// countedTask counts the calls of a job's function, see countRun
+func (s *Server) countedTask(fn reflect.Value, ref *jobRef) reflect.Value {
+	return reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		if id, ok := ref.get(); ok {
+			s.countRun(id)
+		}
+		return call(fn, args)
+	})
+}
+
+// cancellableTask wraps a function which takes a context.Context, so that its execution can be cancelled
+func (s *Server) cancellableTask(fn reflect.Value, ref *jobRef) reflect.Value {
+	return reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		ctx, _ := args[0].Interface().(context.Context)
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if id, ok := ref.get(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+			s.monitor.attachCancel(id, cancel)
+		}
+		args[0] = reflect.ValueOf(&ctx).Elem()
+		return call(fn, args)
+	})
+}
+
+// CancelRun cancels the context of an execution which is in progress.
+// Only tasks which take a context.Context can be cancelled, and only if they respect it.
+func (s *Server) CancelRun(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	if s.monitor == nil {
+		respondError(w, http.StatusNotFound, "Run not found")
+		return
+	}
+
+	found, cancellable := s.monitor.cancelRun(id, vars["runId"])
+	if !found {
+		respondError(w, http.StatusNotFound, "Run not found or already finished")
+		return
+	}
+	if !cancellable {
+		respondError(w, http.StatusConflict, "Cancellation is not supported, the job's task does not take a context.Context")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]string{"message": "Cancellation requested"})
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/rs/cors"
+)
+
+// DefaultCORSMethods are the methods cross-origin requests may use unless CORSConfig sets others
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// DefaultCORSHeaders are the headers cross-origin requests may send unless CORSConfig sets others
+var DefaultCORSHeaders = []string{"Authorization", "Content-Type"}
+
+// exposedHeaders are the response headers scripts of other origins may read, for paging through the jobs
+var exposedHeaders = []string{TotalCountHeader, NextCursorHeader, "Link"}
+
+// CORSConfig configures which other origins may call the API from a browser
+type CORSConfig struct {
+	// AllowedOrigins such as https://ops.example.com, a single wildcard like https://*.example.com is allowed
+	// and "*" allows every origin
+	AllowedOrigins []string
+	AllowedMethods []string // empty falls back to DefaultCORSMethods
+	AllowedHeaders []string // empty falls back to DefaultCORSHeaders, "*" allows every header
+	// AllowCredentials lets browsers send cookies along, it cannot be combined with the origin "*"
+	AllowCredentials bool
+}
+
+// WithCORS allows cross-origin requests from the given origins. The origins may open the WebSocket as well.
+// Without it every origin may call the API without credentials, and only the server's own origin may open
+// the WebSocket. Behind a reverse proxy the public origin has to be listed, unless WithPublicURL or
+// WithForwardedHeaders tell the server its own origin.
+func WithCORS(cfg CORSConfig) Option {
+	return func(s *Server) {
+		s.corsConfig = &cfg
+		s.corsDisabled = false
+	}
+}
+
+// WithoutCORS answers no cross-origin requests, for a UI which is served from the same host as the API
+func WithoutCORS() Option {
+	return func(s *Server) {
+		s.corsConfig = nil
+		s.corsDisabled = true
+	}
+}
+
+// newCORS creates the CORS middleware, nil if CORS is disabled
+func (s *Server) newCORS() (*cors.Cors, error) {
+	if s.corsDisabled {
+		return nil, nil
+	}
+	if s.corsConfig == nil {
+		return cors.New(cors.Options{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: DefaultCORSMethods,
+			AllowedHeaders: []string{"*"},
+			ExposedHeaders: exposedHeaders,
+		}), nil
+	}
+
+	cfg := *s.corsConfig
+	if len(cfg.AllowedOrigins) == 0 {
+		return nil, errors.New("gocron-ui: CORS needs allowed origins, use WithoutCORS to disable it")
+	}
+	if cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*") {
+		return nil, errors.New("gocron-ui: CORS cannot allow credentials for every origin")
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = DefaultCORSMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = DefaultCORSHeaders
+	}
+	c := cors.New(cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+	})
+	s.wsOrigins = c
+	return c, nil
+}
+
+// WithPublicURL sets the URL browsers reach the server at through a reverse proxy, e.g.
+// https://ops.example.com/scheduler. The WebSocket accepts connections from its origin as the server's own.
+func WithPublicURL(publicURL string) Option {
+	return func(s *Server) {
+		s.publicURL = publicURL
+	}
+}
+
+// WithForwardedHeaders takes the server's own origin from the X-Forwarded-Host and X-Forwarded-Proto headers
+// of a reverse proxy, and the client's address in the audit log and traces from X-Forwarded-For. Only use it
+// when a proxy in front of the server sets them, otherwise clients can choose the origin the WebSocket accepts
+// and the address they are recorded with.
+func WithForwardedHeaders() Option {
+	return func(s *Server) {
+		s.trustForwarded = true
+	}
+}
+
+// parsePublicURL checks the URL given to WithPublicURL
+func (s *Server) parsePublicURL() error {
+	if s.publicURL == "" {
+		return nil
+	}
+	u, err := url.Parse(s.publicURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("gocron-ui: public URL %q must be an absolute http or https URL", s.publicURL)
+	}
+	s.publicOrigin = &url.URL{Scheme: u.Scheme, Host: u.Host}
+	return nil
+}
+
+// requestOrigin is the origin a request was sent to, as far as the server can tell
+func (s *Server) requestOrigin(r *http.Request) *url.URL {
+	origin := &url.URL{Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		origin.Scheme = "https"
+	}
+	if s.trustForwarded {
+		// a chain of proxies appends to the headers, the first value is the one the client sent to
+		if host := firstForwarded(r.Header.Get("X-Forwarded-Host")); host != "" {
+			origin.Host = host
+		}
+		if proto := firstForwarded(r.Header.Get("X-Forwarded-Proto")); proto != "" {
+			origin.Scheme = strings.ToLower(proto)
+		}
+	}
+	return origin
+}
+
+func firstForwarded(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
+
+// sameOrigin compares the scheme and the host of two origins, a default port is the same as none
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(originHost(a), originHost(b))
+}
+
+func originHost(u *url.URL) string {
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		return u.Hostname()
+	}
+	return u.Host
+}
+
+// checkOrigin allows WebSocket connections from the server's own origin and the origins given to WithCORS.
+// Browsers do not apply CORS to WebSockets and send the cookies along, so without the check any site
+// could read the job updates of a logged in user. Behind a reverse proxy the server's own origin is only
+// known from WithPublicURL or WithForwardedHeaders, otherwise the proxy's origin has to be given to WithCORS.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// not a browser
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil {
+		if sameOrigin(u, s.requestOrigin(r)) || (s.publicOrigin != nil && sameOrigin(u, s.publicOrigin)) {
+			return true
+		}
+	}
+	return s.wsOrigins != nil && s.wsOrigins.OriginAllowed(r)
+}
@@ -0,0 +1,453 @@
+package server
+
+import (
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is the version of the incremental WebSocket protocol, clients ask for it with /ws?v=2.
+// Clients which do not ask for a version get the full job list whenever it changed, as in version 1.
+const ProtocolVersion = 2
+
+// event types
+const (
+	EventJobAdded    = "jobAdded"
+	EventJobRemoved  = "jobRemoved"
+	EventJobUpdated  = "jobUpdated"
+	EventRunStarted  = "runStarted"
+	EventRunFinished = "runFinished"
+)
+
+// eventBufferSize is the number of recent events kept for clients which resume after reconnecting
+const eventBufferSize = 1000
+
+// subscriberQueue is the number of updates queued for a client, a client which falls further behind is dropped
+const subscriberQueue = 64
+
+// transports of subscribers
+const (
+	transportWebSocket = "websocket"
+	transportSSE       = "sse"
+)
+
+// Event is a change of the jobs. Events are numbered by Seq without gaps, a client only gets the events
+// of the jobs its caller may see though.
+type Event struct {
+	Seq   uint64   `json:"seq"`
+	Type  string   `json:"type"`
+	JobID string   `json:"jobId"`
+	Job   *JobData `json:"job,omitempty"` // jobAdded and jobUpdated
+	Run   any      `json:"run,omitempty"` // an ActiveRun for runStarted, a JobRun for runFinished
+
+	tags         []string // of the job, to decide who may see the event
+	previousTags []string // of the job before a jobUpdated event
+}
+
+// SnapshotMessage is the first message of a version 2 WebSocket connection which does not resume.
+// The events which follow have a Seq greater than the snapshot's.
+type SnapshotMessage struct {
+	Type    string    `json:"type"` // snapshot
+	Version int       `json:"version"`
+	Stream  string    `json:"stream"` // identifies the sequence, it changes when the server restarts
+	Seq     uint64    `json:"seq"`
+	Jobs    []JobData `json:"jobs"`
+}
+
+// ResumedMessage is the first message of a version 2 WebSocket connection which resumes after the
+// sequence number it asked for, the events the client missed follow
+type ResumedMessage struct {
+	Type    string `json:"type"` // resumed
+	Version int    `json:"version"`
+	Stream  string `json:"stream"`
+	Seq     uint64 `json:"seq"`
+}
+
+// eventHub turns the job list of every tick into events by comparing it with the previous one,
+// so that the changes are computed once for all clients
+type eventHub struct {
+	mutex   sync.Mutex
+	stream  string
+	seq     uint64
+	jobs    map[string]JobData // as of seq
+	order   []string           // job IDs in the order of the job list
+	pending []Event            // run events which happened since the last tick
+	buffer  []Event            // the most recent events, oldest first
+	// listening is set while clients are connected. Run events which happen while nobody listens are only
+	// buffered for clients which resume, a client which connects later gets them in its snapshot's past.
+	listening bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		stream: uuid.NewString(),
+		jobs:   make(map[string]JobData),
+	}
+}
+
+// runStarted queues a runStarted event for the next tick
+func (h *eventHub) runStarted(run ActiveRun, tags []string) {
+	h.queue(Event{Type: EventRunStarted, JobID: run.JobID, Run: run, tags: tags})
+}
+
+// runFinished queues a runFinished event for the next tick
+func (h *eventHub) runFinished(run JobRun, tags []string) {
+	h.queue(Event{Type: EventRunFinished, JobID: run.JobID, Run: run, tags: tags})
+}
+
+func (h *eventHub) queue(event Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.listening {
+		h.record([]Event{event})
+		return
+	}
+	if len(h.pending) >= eventBufferSize {
+		// the broadcaster fell behind, events are lost so clients cannot resume and get a snapshot
+		h.pending = h.pending[1:]
+		h.stream = uuid.NewString()
+		h.buffer = nil
+	}
+	h.pending = append(h.pending, event)
+}
+
+// update compares the job list with the previous one and returns the events since the last update
+func (h *eventHub) update(jobs []JobData) []Event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	events := h.pending
+	h.pending = nil
+
+	current := make(map[string]JobData, len(jobs))
+	order := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		current[job.ID] = job
+		order = append(order, job.ID)
+
+		previous, ok := h.jobs[job.ID]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: EventJobAdded, JobID: job.ID, Job: &job, tags: job.Tags})
+		case !reflect.DeepEqual(previous, job):
+			events = append(events, Event{Type: EventJobUpdated, JobID: job.ID, Job: &job, tags: job.Tags, previousTags: previous.Tags})
+		}
+	}
+	for _, id := range h.order {
+		if _, ok := current[id]; !ok {
+			events = append(events, Event{Type: EventJobRemoved, JobID: id, tags: h.jobs[id].Tags})
+		}
+	}
+	h.jobs, h.order = current, order
+
+	h.record(events)
+	return events
+}
+
+// setListening tells the hub whether clients are connected. Once the last one left, the run events
+// which were queued for it are buffered right away.
+func (h *eventHub) setListening(listening bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.listening = listening
+	if !listening {
+		h.record(h.pending)
+		h.pending = nil
+	}
+}
+
+// record numbers events and buffers them, the caller holds the mutex
+func (h *eventHub) record(events []Event) {
+	for i := range events {
+		h.seq++
+		events[i].Seq = h.seq
+	}
+	h.buffer = append(h.buffer, events...)
+	if len(h.buffer) > eventBufferSize {
+		h.buffer = append([]Event(nil), h.buffer[len(h.buffer)-eventBufferSize:]...)
+	}
+}
+
+// snapshot returns the jobs as of the last update together with the stream and its sequence number
+func (h *eventHub) snapshot() ([]JobData, string, uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.list(), h.stream, h.seq
+}
+
+func (h *eventHub) list() []JobData {
+	jobs := make([]JobData, 0, len(h.order))
+	for _, id := range h.order {
+		jobs = append(jobs, h.jobs[id])
+	}
+	return jobs
+}
+
+// since returns the events after a sequence number of the stream, if they are still buffered
+func (h *eventHub) since(stream string, seq uint64) ([]Event, uint64, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if stream != h.stream || seq > h.seq {
+		return nil, 0, false
+	}
+	if seq == h.seq {
+		return nil, h.seq, true
+	}
+	if len(h.buffer) == 0 || h.buffer[0].Seq > seq+1 {
+		return nil, 0, false
+	}
+	i := sort.Search(len(h.buffer), func(i int) bool { return h.buffer[i].Seq > seq })
+	return append([]Event(nil), h.buffer[i:]...), h.seq, true
+}
+
+// visibleEvents leaves out the events of jobs a caller may not see. A job which is not visible anymore after
+// its tags changed is removed from the client, a job which became visible is added.
+func (s *Server) visibleEvents(p *Principal, events []Event) []Event {
+	if s.authz == nil {
+		return events
+	}
+
+	visible := make([]Event, 0, len(events))
+	for _, event := range events {
+		if !s.authz.canJob(p, PermissionView, event.tags) {
+			if event.Type == EventJobUpdated && s.authz.canJob(p, PermissionView, event.previousTags) {
+				visible = append(visible, Event{Seq: event.Seq, Type: EventJobRemoved, JobID: event.JobID})
+			}
+			continue
+		}
+		if event.Type == EventJobUpdated && !s.authz.canJob(p, PermissionView, event.previousTags) {
+			event.Type = EventJobAdded
+		}
+		if event.Job != nil {
+			job := *event.Job
+			job.Permissions = s.authz.jobPermissions(p, job.Tags)
+			event.Job = &job
+		}
+		visible = append(visible, event)
+	}
+	return visible
+}
+
+// eventPosition is where a client which reconnects left off
+type eventPosition struct {
+	stream string
+	seq    uint64
+}
+
+// subscriber is a client of the job updates, connected through a WebSocket or server-sent events.
+// Its messages are written by its own goroutine from a bounded queue, so a slow client cannot hold up the others.
+type subscriber struct {
+	transport string
+	principal *Principal      // the caller, nil without authentication
+	version   int             // of the protocol, 1 gets the full job list on every change
+	filter    *JobFilter      // the jobs the client subscribed to, nil for all
+	known     map[string]bool // IDs of the jobs a filtered client has, only used by the broadcaster and the greeting
+
+	send      chan []any // batches of messages, one per update
+	closing   chan struct{}
+	closeOnce sync.Once
+	closeCode int // a WebSocket close code
+	closeText string
+}
+
+func newSubscriber(transport string, p *Principal, version int, filter *JobFilter) *subscriber {
+	return &subscriber{
+		transport: transport,
+		principal: p,
+		version:   version,
+		filter:    filter,
+		known:     make(map[string]bool),
+		send:      make(chan []any, subscriberQueue),
+		closing:   make(chan struct{}),
+	}
+}
+
+// enqueue queues messages without blocking and drops the client if its queue is full
+func (c *subscriber) enqueue(messages []any) {
+	if len(messages) == 0 {
+		return
+	}
+	select {
+	case c.send <- messages:
+	case <-c.closing:
+	default:
+		log.Printf("Dropping %s client which does not keep up with the updates", c.transport)
+		c.close(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+// close makes the writer of the client stop, WebSocket clients get a close frame with the code
+func (c *subscriber) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeText = code, text
+		close(c.closing)
+	})
+}
+
+// subscribe queues the greeting of a client and registers it for the updates, it returns the number of clients
+func (s *Server) subscribe(c *subscriber, resume *eventPosition) int {
+	// the greeting is queued while holding the lock, so that the broadcaster cannot queue events before it
+	s.clientsMu.Lock()
+	if s.stopping {
+		s.clientsMu.Unlock()
+		c.close(websocket.CloseGoingAway, "server shutting down")
+		return 0
+	}
+	// run events from now on are queued for the broadcaster and come after the greeting
+	s.events.setListening(true)
+	c.enqueue(s.greeting(c, resume))
+	s.clients[c] = struct{}{}
+	s.background.Add(1)
+	total := len(s.clients)
+	s.clientsMu.Unlock()
+
+	// the events are not computed while nobody listens, catch up right away
+	s.notifyJobsChanged()
+	return total
+}
+
+// unsubscribe stops sending updates to a client, it returns the number of clients left
+func (s *Server) unsubscribe(c *subscriber) int {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		s.background.Done()
+		if len(s.clients) == 0 {
+			s.events.setListening(false)
+		}
+	}
+	return len(s.clients)
+}
+
+// greeting is what a client gets first: the jobs, or the events it missed if it resumes.
+// Filtered clients always get a snapshot, the server does not know which jobs they had.
+func (s *Server) greeting(c *subscriber, resume *eventPosition) []any {
+	if c.version == 1 {
+		return []any{map[string]interface{}{
+			"type": "jobs",
+			"data": c.filter.filter(s.visibleJobs(c.principal, s.getJobsData())),
+		}}
+	}
+
+	if resume != nil && c.filter == nil {
+		if events, seq, ok := s.events.since(resume.stream, resume.seq); ok {
+			messages := []any{ResumedMessage{Type: "resumed", Version: ProtocolVersion, Stream: resume.stream, Seq: seq}}
+			for _, event := range s.visibleEvents(c.principal, events) {
+				messages = append(messages, event)
+			}
+			return messages
+		}
+	}
+
+	jobs, stream, seq := s.events.snapshot()
+	jobs = c.filter.filter(s.visibleJobs(c.principal, jobs))
+	if c.filter != nil {
+		for _, job := range jobs {
+			c.known[job.ID] = true
+		}
+	}
+	return []any{SnapshotMessage{
+		Type:    "snapshot",
+		Version: ProtocolVersion,
+		Stream:  stream,
+		Seq:     seq,
+		Jobs:    jobs,
+	}}
+}
+
+// broadcastJobUpdates computes the changes of the jobs once per tick and queues them for all clients
+// until the server shuts down
+func (s *Server) broadcastJobUpdates() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.refresh:
+		case <-s.done:
+			return
+		}
+
+		s.pruneJobs()
+
+		s.clientsMu.RLock()
+		idle := len(s.clients) == 0
+		s.clientsMu.RUnlock()
+		if idle {
+			// the events are computed once a client connects, by comparing with the last job list
+			continue
+		}
+
+		jobs := s.getJobsData()
+
+		// clients only register while holding the write lock, so each gets every event after its greeting
+		s.clientsMu.RLock()
+		events := s.events.update(jobs)
+		if len(events) > 0 {
+			for c := range s.clients {
+				c.enqueue(s.updateMessages(c, events, jobs))
+			}
+		}
+		s.clientsMu.RUnlock()
+	}
+}
+
+// updateMessages are the events a client may see, or the full job list for clients of version 1
+func (s *Server) updateMessages(c *subscriber, events []Event, jobs []JobData) []any {
+	if c.version == 1 {
+		return []any{map[string]interface{}{
+			"type": "jobs",
+			"data": c.filter.filter(s.visibleJobs(c.principal, jobs)),
+		}}
+	}
+	visible := c.filterEvents(s.visibleEvents(c.principal, events))
+	messages := make([]any, 0, len(visible))
+	for _, event := range visible {
+		messages = append(messages, event)
+	}
+	return messages
+}
+
+// filterEvents passes the events of the jobs which match the filter of the client. A job which starts matching
+// is added and one which stops matching is removed, run events pass for the jobs the client has.
+func (c *subscriber) filterEvents(events []Event) []Event {
+	if c.filter == nil {
+		return events
+	}
+
+	result := make([]Event, 0, len(events))
+	for _, event := range events {
+		switch event.Type {
+		case EventJobAdded, EventJobUpdated:
+			switch {
+			case c.filter.matches(*event.Job):
+				if !c.known[event.JobID] {
+					c.known[event.JobID] = true
+					event.Type = EventJobAdded
+				}
+				result = append(result, event)
+			case c.known[event.JobID]:
+				delete(c.known, event.JobID)
+				result = append(result, Event{Seq: event.Seq, Type: EventJobRemoved, JobID: event.JobID})
+			}
+		case EventJobRemoved:
+			if c.known[event.JobID] {
+				delete(c.known, event.JobID)
+				result = append(result, event)
+			}
+		default:
+			if c.known[event.JobID] {
+				result = append(result, event)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package server
+
+import "testing"
+
+// eventTypes lists the types of events
+func eventTypes(events []Event) []string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestEventHubRunsWhileIdle(t *testing.T) {
+	h := newEventHub()
+	jobs := []JobData{{ID: "a", Name: "report"}}
+
+	// a run while nobody listens is only buffered for clients which resume
+	h.runStarted(ActiveRun{JobID: "a"}, nil)
+	h.setListening(true)
+	_, stream, seq := h.snapshot()
+	if seq != 1 {
+		t.Fatalf("snapshot seq = %d, want it after the buffered run", seq)
+	}
+	if events := h.update(jobs); len(events) != 1 || events[0].Type != EventJobAdded {
+		t.Fatalf("events = %v, want only the job added", eventTypes(events))
+	}
+	if events, _, ok := h.since(stream, 0); !ok || len(events) != 2 || events[0].Type != EventRunStarted {
+		t.Errorf("resumed events = %v, want the run started and the job added", eventTypes(events))
+	}
+
+	// runs are sent live while clients listen
+	h.runFinished(JobRun{JobID: "a"}, nil)
+	if events := h.update(jobs); len(events) != 1 || events[0].Type != EventRunFinished {
+		t.Errorf("events = %v, want the run finished", eventTypes(events))
+	}
+
+	// runs queued when the last client left are buffered then and not sent to the next client
+	h.runStarted(ActiveRun{JobID: "a"}, nil)
+	h.setListening(false)
+	h.runFinished(JobRun{JobID: "a"}, nil)
+	h.setListening(true)
+	_, _, seq = h.snapshot()
+	if events := h.update(jobs); len(events) != 0 {
+		t.Errorf("events = %v, want none", eventTypes(events))
+	}
+	if events, _, ok := h.since(stream, 3); !ok || seq != 5 || len(events) != 2 {
+		t.Errorf("resumed events = %v up to %d, want the 2 runs while nobody listened", eventTypes(events), seq)
+	}
+}
+
+func TestSubscribeListens(t *testing.T) {
+	s, _ := newMonitoredServer(t)
+	first := newSubscriber(transportSSE, nil, ProtocolVersion, nil)
+	second := newSubscriber(transportWebSocket, nil, ProtocolVersion, nil)
+
+	listening := func() bool {
+		s.events.mutex.Lock()
+		defer s.events.mutex.Unlock()
+		return s.events.listening
+	}
+	if listening() {
+		t.Fatal("the hub listens without clients")
+	}
+	s.subscribe(first, nil)
+	s.subscribe(second, nil)
+	s.unsubscribe(first)
+	if !listening() {
+		t.Error("the hub stopped listening while a client is connected")
+	}
+	s.unsubscribe(second)
+	if listening() {
+		t.Error("the hub listens after the last client left")
+	}
+}
@@ -0,0 +1,388 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DefaultMaxRunsPerJob is the number of runs kept per job by the in-memory history store
+const DefaultMaxRunsPerJob = 100
+
+// run statuses
+const (
+	RunStatusSuccess   = "success"
+	RunStatusFailed    = "failed"
+	RunStatusCancelled = "cancelled"
+)
+
+// run triggers
+const (
+	TriggerScheduled = "scheduled"
+	TriggerManual    = "manual"
+)
+
+const (
+	defaultRunsPageSize = 20
+	maxRunsPageSize     = 100
+)
+
+// HistoryStore stores the execution history of jobs
+type HistoryStore interface {
+	// Add records a finished run
+	Add(run JobRun) error
+	// List returns the runs of a job newest first, skipping offset runs and returning at most limit runs,
+	// together with the total number of runs stored for the job
+	List(jobID string, offset, limit int) ([]JobRun, int, error)
+}
+
+// Retention limits how much run history is kept
+type Retention struct {
+	// MaxAge drops runs which started longer ago, zero keeps runs regardless of their age
+	MaxAge time.Duration
+	// MaxRunsPerJob is the number of most recent runs kept per job, zero falls back to DefaultMaxRunsPerJob
+	MaxRunsPerJob int
+}
+
+// retentionSweepInterval is how often runs of all jobs are checked against the maximum age
+const retentionSweepInterval = time.Minute
+
+// MemoryHistoryStore is a bounded in-memory HistoryStore which keeps the most recent runs of every job.
+// When created by the server for WithStore it also writes every change through to the store.
+type MemoryHistoryStore struct {
+	mu        sync.RWMutex
+	runs      map[string][]JobRun // oldest first
+	retention Retention
+	store     Store
+	lastSweep time.Time
+}
+
+// NewMemoryHistoryStore creates an in-memory history store keeping at most maxRunsPerJob runs per job.
+// A non-positive value falls back to DefaultMaxRunsPerJob.
+func NewMemoryHistoryStore(maxRunsPerJob int) *MemoryHistoryStore {
+	return newHistoryStore(Retention{MaxRunsPerJob: maxRunsPerJob})
+}
+
+func newHistoryStore(retention Retention) *MemoryHistoryStore {
+	if retention.MaxRunsPerJob <= 0 {
+		retention.MaxRunsPerJob = DefaultMaxRunsPerJob
+	}
+	return &MemoryHistoryStore{
+		runs:      make(map[string][]JobRun),
+		retention: retention,
+		lastSweep: time.Now(),
+	}
+}
+
+// newPersistentHistoryStore loads the run history from a store and writes every change through to it
+func newPersistentHistoryStore(store Store, retention Retention) (*MemoryHistoryStore, error) {
+	h := newHistoryStore(retention)
+
+	values, err := store.List(bucketRuns)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		var run JobRun
+		if err := json.Unmarshal(value, &run); err != nil {
+			log.Printf("Dropping unreadable run %s from store: %v", key, err)
+			_ = store.Delete(bucketRuns, key)
+			continue
+		}
+		h.runs[run.JobID] = append(h.runs[run.JobID], run)
+	}
+
+	h.store = store
+	for jobID, runs := range h.runs {
+		sortRuns(runs)
+		h.prune(jobID, time.Now())
+	}
+	return h, nil
+}
+
+// Add records a finished run, evicting runs of the job which are beyond the retention
+func (m *MemoryHistoryStore) Add(run JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.store != nil {
+		value, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		if err := m.store.Put(bucketRuns, runKey(run), value); err != nil {
+			return err
+		}
+	}
+
+	runs := append(m.runs[run.JobID], run)
+	// runs may finish out of order when a job is allowed to overlap
+	if n := len(runs); n > 1 && runs[n-1].StartedAt.Before(runs[n-2].StartedAt) {
+		sortRuns(runs)
+	}
+	m.runs[run.JobID] = runs
+
+	now := time.Now()
+	m.prune(run.JobID, now)
+	if m.retention.MaxAge > 0 && now.Sub(m.lastSweep) >= retentionSweepInterval {
+		// jobs which no longer run would otherwise keep their expired runs forever
+		m.lastSweep = now
+		for jobID := range m.runs {
+			m.prune(jobID, now)
+		}
+	}
+	return nil
+}
+
+// prune drops the runs of a job which are beyond the retention, m.mu must be held
+func (m *MemoryHistoryStore) prune(jobID string, now time.Time) {
+	runs := m.runs[jobID]
+
+	drop := 0
+	if len(runs) > m.retention.MaxRunsPerJob {
+		drop = len(runs) - m.retention.MaxRunsPerJob
+	}
+	if m.retention.MaxAge > 0 {
+		cutoff := now.Add(-m.retention.MaxAge)
+		for drop < len(runs) && runs[drop].StartedAt.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return
+	}
+
+	if m.store != nil {
+		for _, run := range runs[:drop] {
+			if err := m.store.Delete(bucketRuns, runKey(run)); err != nil {
+				log.Printf("Error deleting run %s from store: %v", run.ID, err)
+			}
+		}
+	}
+
+	if drop == len(runs) {
+		delete(m.runs, jobID)
+		return
+	}
+	m.runs[jobID] = append([]JobRun(nil), runs[drop:]...)
+}
+
+// List returns the runs of a job newest first
+func (m *MemoryHistoryStore) List(jobID string, offset, limit int) ([]JobRun, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return pageRuns(m.runs[jobID], offset, limit), len(m.runs[jobID]), nil
+}
+
+func runKey(run JobRun) string {
+	return run.JobID + "/" + run.ID
+}
+
+func sortRuns(runs []JobRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+}
+
+// pageRuns returns a newest-first page of runs which are stored oldest first
+func pageRuns(runs []JobRun, offset, limit int) []JobRun {
+	if offset < 0 {
+		offset = 0
+	}
+	total := len(runs)
+	if offset >= total || limit <= 0 {
+		return []JobRun{}
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	result := make([]JobRun, 0, end-offset)
+	for i := offset; i < end; i++ {
+		result = append(result, runs[total-1-i])
+	}
+	return result
+}
+
+// GetJobRuns gets a page of a job's run history, newest first
+func (s *Server) GetJobRuns(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultRunsPageSize)
+	if err != nil || limit <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	if limit > maxRunsPageSize {
+		limit = maxRunsPageSize
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		respondError(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+
+	runs, total, err := s.history.List(id.String(), offset, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JobRunsResponse{
+		Runs:   runs,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// recordRun turns a finished execution reported by the monitor into a history entry
+func (s *Server) recordRun(rec runRecord) {
+	run := JobRun{
+		ID:         uuid.NewString(),
+		JobID:      rec.jobID.String(),
+		JobName:    rec.jobName,
+		StartedAt:  rec.startedAt,
+		FinishedAt: rec.endedAt,
+		DurationMs: rec.endedAt.Sub(rec.startedAt).Milliseconds(),
+		Status:     RunStatusSuccess,
+		Trigger:    TriggerScheduled,
+	}
+	if rec.run != nil {
+		// the execution was tracked since it started, so the run keeps the ID it was listed with while running
+		run.ID = rec.run.ID
+		run.Trigger = rec.run.Trigger
+	} else if s.takeManualRun(rec.jobID) {
+		run.Trigger = TriggerManual
+	}
+	if rec.err != nil {
+		run.Status = RunStatusFailed
+		run.Error = rec.err.Error()
+		run.Panicked = errors.Is(rec.err, gocron.ErrPanicRecovered)
+	}
+	if rec.run != nil && rec.run.cancelled {
+		run.Status = RunStatusCancelled
+	}
+
+	// counted before the run is added, so that failures in a row loaded from the history do not include it
+	previous, streak := s.countFailure(run)
+	if err := s.history.Add(run); err != nil {
+		log.Printf("Error recording run of job %s: %v", run.JobID, err)
+	}
+	if s.metrics != nil {
+		s.metrics.observeRun(run, rec.tags)
+	}
+	s.recordRunSpan(run, rec.tags)
+	s.events.runFinished(run, rec.tags)
+	if s.alerts != nil {
+		s.alerts.observeRun(run, rec.tags, previous, streak)
+	}
+}
+
+// consecutiveFailures returns how many of the latest runs of a job failed in a row, cancelled runs are skipped
+func (s *Server) consecutiveFailures(jobID string) int {
+	if s.monitor == nil {
+		return 0
+	}
+	s.failuresMutex.Lock()
+	defer s.failuresMutex.Unlock()
+	n, ok := s.failures[jobID]
+	if !ok {
+		n = s.loadFailures(jobID)
+		s.failures[jobID] = n
+	}
+	return n
+}
+
+// countFailure updates the failures in a row of a job with a finished run which is not in the history yet,
+// and returns them from before and after the run
+func (s *Server) countFailure(run JobRun) (int, int) {
+	s.failuresMutex.Lock()
+	defer s.failuresMutex.Unlock()
+	previous, ok := s.failures[run.JobID]
+	if !ok {
+		previous = s.loadFailures(run.JobID)
+	}
+	n := previous
+	switch run.Status {
+	case RunStatusSuccess:
+		n = 0
+	case RunStatusFailed:
+		n++
+	}
+	s.failures[run.JobID] = n
+	return previous, n
+}
+
+// loadFailures counts the failures in a row from the run history, e.g. after a restart with a store
+func (s *Server) loadFailures(jobID string) int {
+	failures, offset := 0, 0
+	for {
+		runs, total, err := s.history.List(jobID, offset, maxRunsPageSize)
+		if err != nil {
+			log.Printf("Error loading runs of job %s: %v", jobID, err)
+			return failures
+		}
+		for _, run := range runs {
+			switch run.Status {
+			case RunStatusSuccess:
+				return failures
+			case RunStatusFailed:
+				failures++
+			}
+		}
+		offset += len(runs)
+		if len(runs) == 0 || offset >= total {
+			return failures
+		}
+	}
+}
+
+// markManualRun remembers that the next run of the job was triggered through the API.
+// gocron does not tell monitors why a job ran, so this is attributed on a best-effort basis.
+func (s *Server) markManualRun(id uuid.UUID) {
+	s.runsMutex.Lock()
+	s.manualRuns[id]++
+	s.runsMutex.Unlock()
+}
+
+// takeManualRun consumes a pending manual trigger of the job, if any
+func (s *Server) takeManualRun(id uuid.UUID) bool {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+
+	if s.manualRuns[id] == 0 {
+		return false
+	}
+	s.manualRuns[id]--
+	if s.manualRuns[id] == 0 {
+		delete(s.manualRuns, id)
+	}
+	return true
+}
+
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
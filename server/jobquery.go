@@ -0,0 +1,327 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tag modes of JobFilter
+const (
+	TagModeAny = "any"
+	TagModeAll = "all"
+)
+
+// job states of JobFilter
+const (
+	JobStateRunning = "running"
+	JobStatePaused  = "paused"
+	JobStateFailing = "failing" // the latest run failed
+)
+
+// fields GET /api/jobs sorts by
+const (
+	SortNextRun  = "nextRun"
+	SortLastRun  = "lastRun"
+	SortName     = "name"
+	SortFailures = "failures" // failed runs in a row
+)
+
+// maxJobsPageSize limits the limit of GET /api/jobs
+const maxJobsPageSize = 500
+
+// response headers of GET /api/jobs
+const (
+	TotalCountHeader = "X-Total-Count" // the number of matching jobs
+	NextCursorHeader = "X-Next-Cursor" // the cursor of the next page, missing on the last page
+)
+
+// JobFilter selects jobs by their tags, name and state. GET /api/jobs, the WebSocket and the event stream
+// take it as the query parameters tag, tagMode, name and state.
+type JobFilter struct {
+	Tags    []string // the job has any of the tags, or all of them with TagModeAll
+	TagMode string
+	// Name is a case-insensitive substring of the job's name, or a pattern for the whole name if it
+	// contains * or ?
+	Name  string
+	State string // JobStateRunning, JobStatePaused or JobStateFailing
+
+	namePattern *regexp.Regexp
+}
+
+// parseJobFilter reads a filter from query parameters, it returns nil if there is none
+func parseJobFilter(query url.Values) (*JobFilter, error) {
+	f := &JobFilter{
+		Tags:    splitQuery(query["tag"]),
+		TagMode: query.Get("tagMode"),
+		Name:    query.Get("name"),
+		State:   query.Get("state"),
+	}
+	if len(f.Tags) == 0 && f.TagMode == "" && f.Name == "" && f.State == "" {
+		return nil, nil
+	}
+
+	errs := fieldErrors{}
+	switch f.TagMode {
+	case "":
+		f.TagMode = TagModeAny
+	case TagModeAny, TagModeAll:
+	default:
+		errs["tagMode"] = "Invalid tag mode. Supported: any, all"
+	}
+	switch f.State {
+	case "", JobStateRunning, JobStatePaused, JobStateFailing:
+	default:
+		errs["state"] = "Invalid state. Supported: running, paused, failing"
+	}
+	if strings.ContainsAny(f.Name, "*?") {
+		pattern := regexp.QuoteMeta(strings.ToLower(f.Name))
+		pattern = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(pattern)
+		f.namePattern = regexp.MustCompile("^" + pattern + "$")
+	}
+	if err := errs.errOrNil(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// splitQuery accepts repeated and comma-separated values
+func splitQuery(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// matches reports whether a job passes the filter, a nil filter passes every job
+func (f *JobFilter) matches(job JobData) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Tags) > 0 {
+		found := 0
+		for _, tag := range f.Tags {
+			if slices.Contains(job.Tags, tag) {
+				found++
+			}
+		}
+		if found == 0 || (f.TagMode == TagModeAll && found < len(f.Tags)) {
+			return false
+		}
+	}
+
+	name := strings.ToLower(job.Name)
+	if f.namePattern != nil {
+		if !f.namePattern.MatchString(name) {
+			return false
+		}
+	} else if !strings.Contains(name, strings.ToLower(f.Name)) {
+		return false
+	}
+
+	switch f.State {
+	case JobStateRunning:
+		return job.Running
+	case JobStatePaused:
+		return job.Paused
+	case JobStateFailing:
+		return job.ConsecutiveFailures > 0
+	}
+	return true
+}
+
+// filter returns the jobs which pass the filter
+func (f *JobFilter) filter(jobs []JobData) []JobData {
+	if f == nil {
+		return jobs
+	}
+	result := make([]JobData, 0, len(jobs))
+	for _, job := range jobs {
+		if f.matches(job) {
+			result = append(result, job)
+		}
+	}
+	return result
+}
+
+// jobOrder sorts jobs by a field, jobs without a value for it come last in both directions and ties are
+// broken by ID, so that every job has a fixed position which a cursor can point to
+type jobOrder struct {
+	field string
+	desc  bool
+}
+
+// parseJobOrder reads the sort parameter, a field optionally prefixed with - for descending order
+func parseJobOrder(value string) (jobOrder, error) {
+	order := jobOrder{field: strings.TrimPrefix(value, "-"), desc: strings.HasPrefix(value, "-")}
+	switch order.field {
+	case SortNextRun, SortLastRun, SortName, SortFailures:
+		return order, nil
+	}
+	return jobOrder{}, invalidField("sort", "Invalid sort. Supported: nextRun, lastRun, name, failures, prefixed with - for descending order")
+}
+
+// key is the value a job is sorted by, keys of the same field compare like the values they stand for
+func (o jobOrder) key(job JobData) string {
+	switch o.field {
+	case SortNextRun:
+		return timeKey(job.NextRun)
+	case SortLastRun:
+		return timeKey(job.LastRun)
+	case SortName:
+		return strings.ToLower(job.Name)
+	case SortFailures:
+		return fmt.Sprintf("%010d", job.ConsecutiveFailures)
+	}
+	return ""
+}
+
+// timeKey turns a formatted time into a string which sorts like the time, empty if there is none
+func timeKey(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// compare orders two jobs given by their key and ID
+func (o jobOrder) compare(keyA, idA, keyB, idB string) int {
+	switch {
+	case keyA == keyB:
+	case keyA == "":
+		return 1
+	case keyB == "":
+		return -1
+	case o.desc:
+		return strings.Compare(keyB, keyA)
+	default:
+		return strings.Compare(keyA, keyB)
+	}
+	return strings.Compare(idA, idB)
+}
+
+func (o jobOrder) sort(jobs []JobData) {
+	keys := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		keys[job.ID] = o.key(job)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return o.compare(keys[jobs[i].ID], jobs[i].ID, keys[jobs[j].ID], jobs[j].ID) < 0
+	})
+}
+
+// jobCursor points after the last job of a page by its sort key and ID, so that paging goes on where it left
+// off when jobs are added, removed or move while the client pages
+type jobCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+func (c jobCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJobCursor(value string) (jobCursor, error) {
+	var c jobCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.ID == "" {
+		return jobCursor{}, invalidField("cursor", "Invalid cursor")
+	}
+	return c, nil
+}
+
+// GetJobs gets the jobs, all of them in the order of the scheduler unless the query filters, sorts or pages them.
+// Pages need an order, they are sorted by name unless the query sorts them otherwise.
+func (s *Server) GetJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	errs := fieldErrors{}
+
+	filter, err := parseJobFilter(query)
+	if err := errs.merge(err); err != nil {
+		respondJobError(w, err)
+		return
+	}
+
+	var order *jobOrder
+	if value := query.Get("sort"); value != "" {
+		parsed, err := parseJobOrder(value)
+		if err := errs.merge(err); err != nil {
+			respondJobError(w, err)
+			return
+		}
+		order = &parsed
+	}
+
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil || limit < 0 || (limit == 0 && query.Has("limit")) {
+		errs["limit"] = "Invalid limit"
+	}
+	limit = min(limit, maxJobsPageSize)
+	var cursor *jobCursor
+	if value := query.Get("cursor"); value != "" {
+		parsed, err := decodeJobCursor(value)
+		if err := errs.merge(err); err != nil {
+			respondJobError(w, err)
+			return
+		}
+		cursor = &parsed
+	}
+	if (limit > 0 || cursor != nil) && order == nil {
+		order = &jobOrder{field: SortName}
+	}
+	if cursor != nil && cursor.Sort != query.Get("sort") {
+		errs["cursor"] = "The cursor belongs to another sort order"
+	}
+	if err := errs.errOrNil(); err != nil {
+		respondJobError(w, err)
+		return
+	}
+
+	p, _ := PrincipalFromContext(r.Context())
+	jobs := filter.filter(s.visibleJobs(p, s.getJobsData()))
+	w.Header().Set(TotalCountHeader, strconv.Itoa(len(jobs)))
+	if order == nil {
+		respondJSON(w, http.StatusOK, jobs)
+		return
+	}
+
+	order.sort(jobs)
+	if cursor != nil {
+		// the first job after the cursor
+		i := sort.Search(len(jobs), func(i int) bool {
+			return order.compare(cursor.Key, cursor.ID, order.key(jobs[i]), jobs[i].ID) < 0
+		})
+		jobs = jobs[i:]
+	}
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+		last := jobs[limit-1]
+		next := jobCursor{Sort: query.Get("sort"), Key: order.key(last), ID: last.ID}.encode()
+		w.Header().Set(NextCursorHeader, next)
+
+		query.Set("cursor", next)
+		nextURL := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.String()))
+	}
+	respondJSON(w, http.StatusOK, jobs)
+}
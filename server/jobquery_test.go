@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJobFilterName(t *testing.T) {
+	tests := []struct {
+		name string
+		job  string
+		want bool
+	}{
+		{name: "port", job: "Daily Report", want: true},
+		{name: "PORT", job: "daily report", want: true},
+		{name: "weekly", job: "Daily Report", want: false},
+		// a pattern matches the whole name
+		{name: "port*", job: "Daily Report", want: false},
+		{name: "*port", job: "Daily Report", want: true},
+		{name: "daily*", job: "Daily Report", want: true},
+		{name: "daily re?ort", job: "Daily Report", want: true},
+		{name: "daily re?ort", job: "Daily Reort", want: false},
+		// only * and ? are special
+		{name: "a.b*", job: "axb job", want: false},
+		{name: "a.b*", job: "a.b job", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.job, func(t *testing.T) {
+			filter, err := parseJobFilter(url.Values{"name": {tt.name}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.matches(JobData{Name: tt.job}); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobFilterTags(t *testing.T) {
+	job := JobData{Name: "report", Tags: []string{"ops", "billing"}}
+	tests := []struct {
+		query url.Values
+		want  bool
+	}{
+		{query: url.Values{"tag": {"ops"}}, want: true},
+		{query: url.Values{"tag": {"ops,eu"}}, want: true},
+		{query: url.Values{"tag": {"ops", "eu"}, "tagMode": {TagModeAny}}, want: true},
+		{query: url.Values{"tag": {"eu"}}, want: false},
+		{query: url.Values{"tag": {"ops,billing"}, "tagMode": {TagModeAll}}, want: true},
+		{query: url.Values{"tag": {"ops", "eu"}, "tagMode": {TagModeAll}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query.Encode(), func(t *testing.T) {
+			filter, err := parseJobFilter(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.matches(job); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseJobFilter(url.Values{"tag": {"ops"}, "tagMode": {"some"}}); err == nil {
+		t.Error("parsed an invalid tag mode")
+	}
+}
+
+// jobPage gets a page of jobs and returns their names
+func jobPage(t *testing.T, s *Server, path string) ([]string, http.Header) {
+	t.Helper()
+	rec := serve(s, http.MethodGet, path, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d: %s", path, rec.Code, rec.Body.String())
+	}
+	var jobs []JobData
+	if err := json.Unmarshal(rec.Body.Bytes(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	return names, rec.Header()
+}
+
+// nextLink returns the target of the next link of a page
+func nextLink(t *testing.T, header http.Header) string {
+	t.Helper()
+	link := header.Get("Link")
+	target, ok := strings.CutSuffix(link, `>; rel="next"`)
+	if !ok || !strings.HasPrefix(target, "<") {
+		t.Fatalf("Link = %q", link)
+	}
+	return strings.TrimPrefix(target, "<")
+}
+
+func TestGetJobsPages(t *testing.T) {
+	s := newAPIServer(t)
+	for _, name := range []string{"e", "c", "a", "d", "b"} {
+		createJob(t, s, `{"name":"`+name+`","type":"duration","interval":3600,"task":"noop","tags":["ops"]}`)
+	}
+	createJob(t, s, `{"name":"other","type":"duration","interval":3600,"task":"noop"}`)
+
+	names, header := jobPage(t, s, "/api/jobs?tag=ops&limit=2")
+	if strings.Join(names, ",") != "a,b" {
+		t.Fatalf("first page = %v", names)
+	}
+	if got := header.Get(TotalCountHeader); got != "5" {
+		t.Errorf("%s = %s, want the 5 matching jobs", TotalCountHeader, got)
+	}
+	cursor := header.Get(NextCursorHeader)
+	if cursor == "" {
+		t.Fatal("no cursor for the next page")
+	}
+	next, err := url.Parse(nextLink(t, header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the link repeats the query with the cursor
+	if next.Path != "/api/jobs" || next.Query().Get("cursor") != cursor || next.Query().Get("limit") != "2" || next.Query().Get("tag") != "ops" {
+		t.Errorf("next link = %s", next)
+	}
+
+	names, header = jobPage(t, s, next.String())
+	if strings.Join(names, ",") != "c,d" {
+		t.Fatalf("second page = %v", names)
+	}
+	names, header = jobPage(t, s, nextLink(t, header))
+	if strings.Join(names, ",") != "e" {
+		t.Fatalf("last page = %v", names)
+	}
+	if header.Get(NextCursorHeader) != "" || header.Get("Link") != "" {
+		t.Errorf("the last page links to another: %v", header)
+	}
+
+	// descending pages
+	names, header = jobPage(t, s, "/api/jobs?tag=ops&sort=-name&limit=3")
+	if strings.Join(names, ",") != "e,d,c" {
+		t.Fatalf("first descending page = %v", names)
+	}
+	names, _ = jobPage(t, s, nextLink(t, header))
+	if strings.Join(names, ",") != "b,a" {
+		t.Errorf("second descending page = %v", names)
+	}
+}
+
+func TestGetJobsCursorOfDeletedJob(t *testing.T) {
+	s := newAPIServer(t)
+	ids := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		ids[name] = createJob(t, s, `{"name":"`+name+`","type":"duration","interval":3600,"task":"noop"}`)
+	}
+
+	names, header := jobPage(t, s, "/api/jobs?limit=2")
+	if strings.Join(names, ",") != "a,b" {
+		t.Fatalf("first page = %v", names)
+	}
+	// the job the cursor points to is deleted before the next page is fetched
+	if rec := serve(s, http.MethodDelete, "/api/jobs/"+ids["b"], ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	names, header = jobPage(t, s, nextLink(t, header))
+	if strings.Join(names, ",") != "c,d" {
+		t.Errorf("next page = %v, want the jobs after the deleted one", names)
+	}
+	if got := header.Get(TotalCountHeader); got != "3" {
+		t.Errorf("%s = %s, want 3", TotalCountHeader, got)
+	}
+}
+
+func TestGetJobsInvalidQuery(t *testing.T) {
+	s := newAPIServer(t)
+	cursor := jobCursor{Sort: SortLastRun, ID: "x"}.encode()
+	for _, query := range []string{
+		"limit=0",
+		"limit=-1",
+		"sort=size",
+		"cursor=not-a-cursor",
+		"cursor=" + cursor,
+		"state=sleeping",
+	} {
+		if rec := serve(s, http.MethodGet, "/api/jobs?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
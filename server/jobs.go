@@ -0,0 +1,730 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// scheduleFields are the request fields which describe a job's schedule
+var scheduleFields = []string{
+	"type", "interval", "minInterval", "maxInterval", "cronExpression",
+	"atTime", "atTimes", "weekdays", "daysOfMonth", "times",
+}
+
+// Task is a function to run together with its parameters, see gocron.NewTask
+type Task struct {
+	function   any
+	parameters []any
+}
+
+// NewTask creates a task for Server.NewJob the same way gocron.NewTask does
+func NewTask(function any, parameters ...any) Task {
+	return Task{function: function, parameters: parameters}
+}
+
+func (t Task) gocronTask() gocron.Task {
+	return gocron.NewTask(t.function, t.parameters...)
+}
+
+// managedJob is a job registered through the server, which knows how it was defined
+type managedJob struct {
+	spec    ScheduleSpec
+	task    Task
+	options []gocron.JobOption
+	name    string
+	tags    []string
+	request *CreateJobRequest // only set for jobs created through the API
+	paused  *PauseInfo        // set while the job is paused and not in the scheduler
+	lastRun time.Time         // last run before the job was paused
+}
+
+// jobOptions returns the options to register the job again, with its current name and tags
+func (mj *managedJob) jobOptions() []gocron.JobOption {
+	options := make([]gocron.JobOption, 0, len(mj.options)+2)
+	options = append(options, mj.options...)
+	return append(options, gocron.WithName(mj.name), gocron.WithTags(mj.tags...))
+}
+
+// currentRequest describes the job's current definition as far as a request can
+func (mj *managedJob) currentRequest() CreateJobRequest {
+	var req CreateJobRequest
+	if mj.request != nil {
+		req = *mj.request
+		// copy the params so that a patch does not modify the current definition
+		req.Params = make(map[string]any, len(mj.request.Params))
+		for name, value := range mj.request.Params {
+			req.Params[name] = value
+		}
+		req.Options = mj.request.Options.copy()
+	}
+	req.Name = mj.name
+	req.Tags = append([]string(nil), mj.tags...)
+	return req
+}
+
+// NewJob adds a job to the scheduler like gocron.Scheduler.NewJob does, and records its schedule so that
+// the UI shows it accurately. Jobs registered directly on the scheduler only have their schedule inferred
+// from their upcoming runs and cannot be updated through the API.
+func (s *Server) NewJob(spec ScheduleSpec, task Task, options ...gocron.JobOption) (gocron.Job, error) {
+	return s.registerJob(uuid.Nil, spec, task, options, nil)
+}
+
+// registerJob adds a job to the scheduler and remembers its definition.
+// The id is only given when the options set it, otherwise it is not known before the job was added.
+func (s *Server) registerJob(id uuid.UUID, spec ScheduleSpec, task Task, options []gocron.JobOption, req *CreateJobRequest) (gocron.Job, error) {
+	jobDef, err := spec.definition()
+	if err != nil {
+		return nil, err
+	}
+
+	jobOptions := options
+	if id == uuid.Nil {
+		// the ID is chosen up front so that the task knows it from the first run on,
+		// a gocron.WithIdentifier among the job's own options comes later and wins
+		id = uuid.New()
+		jobOptions = append([]gocron.JobOption{gocron.WithIdentifier(id)}, options...)
+	}
+
+	// a job which starts right away may run before its definition is stored
+	ref := newPendingJobRef(id)
+	defer ref.release()
+	if s.monitor != nil {
+		s.monitor.trackTask(id)
+	}
+	job, err := s.Scheduler.NewJob(jobDef, s.gocronTask(task, ref), jobOptions...)
+	if err != nil {
+		if s.monitor != nil && !s.jobExists(id) {
+			s.monitor.untrackTask(id, false)
+		}
+		return nil, schedulerError(err)
+	}
+	if job.ID() != id {
+		// the BeforeJobRuns listener reports the starts of a job with an ID of its own
+		if s.monitor != nil {
+			s.monitor.untrackTask(id, true)
+		}
+		ref.set(job.ID())
+	}
+
+	s.jobsMutex.Lock()
+	s.jobs[job.ID()] = &managedJob{
+		spec:    spec,
+		task:    task,
+		options: options,
+		name:    job.Name(),
+		tags:    job.Tags(),
+		request: req,
+	}
+	s.jobsMutex.Unlock()
+
+	s.restorePause(job)
+	s.notifyJobsChanged()
+	return job, nil
+}
+
+// jobName returns the name of a job, from its definition if it was registered through the server
+func (s *Server) jobName(id uuid.UUID) string {
+	if mj, ok := s.managedJob(id); ok {
+		return mj.name
+	}
+	if job, ok := s.findJob(id); ok {
+		return job.Name()
+	}
+	return ""
+}
+
+// managedJob returns the definition of a job registered through the server, if any
+func (s *Server) managedJob(id uuid.UUID) (*managedJob, bool) {
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+
+	mj, ok := s.jobs[id]
+	return mj, ok
+}
+
+// jobLock serializes the changes of a job's definition
+type jobLock struct {
+	sync.Mutex
+	holders int // the holder and the waiters, the lock is dropped once there are none
+}
+
+// lockJob locks the definition of a job until the returned function is called, so that concurrent updates,
+// pauses, resumes and deletes of the job do not undo each other
+func (s *Server) lockJob(id uuid.UUID) func() {
+	s.jobLocksMutex.Lock()
+	l, ok := s.jobLocks[id]
+	if !ok {
+		l = &jobLock{}
+		s.jobLocks[id] = l
+	}
+	l.holders++
+	s.jobLocksMutex.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.jobLocksMutex.Lock()
+		l.holders--
+		if l.holders == 0 {
+			delete(s.jobLocks, id)
+		}
+		s.jobLocksMutex.Unlock()
+	}
+}
+
+// unregisterJob forgets the definition of a removed job
+func (s *Server) unregisterJob(id uuid.UUID) {
+	s.jobsMutex.Lock()
+	delete(s.jobs, id)
+	s.jobsMutex.Unlock()
+	s.resetRunCount(id)
+	if s.monitor != nil {
+		s.monitor.untrackTask(id, false)
+	}
+}
+
+// jobData describes a scheduled or paused job
+func (s *Server) jobData(id uuid.UUID) (JobData, bool) {
+	if job, ok := s.findJob(id); ok {
+		return s.convertJobToData(job), true
+	}
+	if mj, ok := s.managedJob(id); ok && mj.paused != nil {
+		jobData := convertPausedJob(id, mj)
+		s.setRunning(&jobData, id)
+		jobData.ConsecutiveFailures = s.consecutiveFailures(jobData.ID)
+		return jobData, true
+	}
+	return JobData{}, false
+}
+
+// jobExists reports whether a job with the ID is scheduled or paused
+func (s *Server) jobExists(id uuid.UUID) bool {
+	if _, ok := s.managedJob(id); ok {
+		return true
+	}
+	_, ok := s.findJob(id)
+	return ok
+}
+
+// findJob looks up a job of the scheduler by its ID
+func (s *Server) findJob(id uuid.UUID) (gocron.Job, bool) {
+	for _, job := range s.Scheduler.Jobs() {
+		if job.ID() == id {
+			return job, true
+		}
+	}
+	return nil, false
+}
+
+// requestSpec builds the schedule described by a request
+func requestSpec(req CreateJobRequest) (ScheduleSpec, error) {
+	errs := fieldErrors{}
+	var spec ScheduleSpec
+
+	switch req.Type {
+	case ScheduleDuration:
+		if req.Interval <= 0 {
+			return ScheduleSpec{}, invalidField("interval", "Interval must be positive for duration jobs")
+		}
+		return DurationJob(time.Duration(req.Interval) * time.Second), nil
+
+	case ScheduleRandom:
+		if req.MinInterval <= 0 {
+			errs["minInterval"] = "Minimum interval must be positive for random jobs"
+		}
+		if req.MaxInterval <= 0 {
+			errs["maxInterval"] = "Maximum interval must be positive for random jobs"
+		} else if req.MaxInterval < req.MinInterval {
+			errs["maxInterval"] = "Maximum interval must not be less than the minimum interval"
+		}
+		spec = DurationRandomJob(time.Duration(req.MinInterval)*time.Second, time.Duration(req.MaxInterval)*time.Second)
+
+	case ScheduleCron:
+		if req.CronExpression == "" {
+			return ScheduleSpec{}, invalidField("cronExpression", "Cron expression is required for cron jobs")
+		}
+		return CronJob(req.CronExpression, false), nil
+
+	case ScheduleDaily:
+		if req.Interval <= 0 {
+			errs["interval"] = "Interval must be positive for daily jobs"
+		}
+		if err := errs.merge(validateAtTimes(req, "daily")); err != nil {
+			return ScheduleSpec{}, err
+		}
+		spec = DailyJob(uint(req.Interval), requestAtTimes(req)...)
+
+	case ScheduleWeekly:
+		if req.Interval <= 0 {
+			errs["interval"] = "Interval must be positive for weekly jobs"
+		}
+		weekdays := make([]time.Weekday, 0, len(req.Weekdays))
+		for _, value := range req.Weekdays {
+			weekday, ok := parseWeekday(value)
+			if !ok {
+				errs["weekdays"] = fmt.Sprintf("Invalid weekday %q", value)
+				break
+			}
+			weekdays = append(weekdays, weekday)
+		}
+		if len(req.Weekdays) == 0 {
+			errs["weekdays"] = "At least one weekday is required for weekly jobs"
+		}
+		if err := errs.merge(validateAtTimes(req, "weekly")); err != nil {
+			return ScheduleSpec{}, err
+		}
+		spec = WeeklyJob(uint(req.Interval), weekdays, requestAtTimes(req)...)
+
+	case ScheduleMonthly:
+		if req.Interval <= 0 {
+			errs["interval"] = "Interval must be positive for monthly jobs"
+		}
+		if len(req.DaysOfMonth) == 0 {
+			errs["daysOfMonth"] = "At least one day of the month is required for monthly jobs"
+		}
+		if err := errs.merge(validateAtTimes(req, "monthly")); err != nil {
+			return ScheduleSpec{}, err
+		}
+		spec = MonthlyJob(uint(req.Interval), req.DaysOfMonth, requestAtTimes(req)...)
+
+	case ScheduleOneTime:
+		if len(req.Times) == 0 {
+			return ScheduleSpec{}, invalidField("times", "At least one time is required for one-time jobs")
+		}
+		now := time.Now()
+		times := make([]time.Time, 0, len(req.Times))
+		for _, value := range req.Times {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return ScheduleSpec{}, invalidField("times", fmt.Sprintf("Invalid time %q. Use RFC3339", value))
+			}
+			if !t.After(now) {
+				return ScheduleSpec{}, invalidField("times", fmt.Sprintf("Time %q is in the past", value))
+			}
+			times = append(times, t)
+		}
+		return OneTimeJob(times...), nil
+
+	default:
+		return ScheduleSpec{}, invalidField("type", "Invalid job type. Supported: duration, random, cron, daily, weekly, monthly, onetime")
+	}
+
+	if err := errs.errOrNil(); err != nil {
+		return ScheduleSpec{}, err
+	}
+	// the spec validates what is left, such as the range of the days of the month
+	if _, err := spec.definition(); err != nil {
+		return ScheduleSpec{}, err
+	}
+	return spec, nil
+}
+
+// requestAtTimes returns the times of day of a request, atTime being the older form of a single time
+func requestAtTimes(req CreateJobRequest) []string {
+	if len(req.AtTimes) == 0 && req.AtTime != "" {
+		return []string{req.AtTime}
+	}
+	return req.AtTimes
+}
+
+func validateAtTimes(req CreateJobRequest, jobType string) error {
+	field := "atTimes"
+	if len(req.AtTimes) == 0 && req.AtTime != "" {
+		field = "atTime"
+	}
+
+	atTimes := requestAtTimes(req)
+	if len(atTimes) == 0 {
+		return invalidField(field, fmt.Sprintf("At least one time of day is required for %s jobs", jobType))
+	}
+	for _, value := range atTimes {
+		if _, err := parseTime(value); err != nil {
+			return invalidField(field, fmt.Sprintf("Invalid time format %q. Use HH:MM:SS", value))
+		}
+	}
+	return nil
+}
+
+// removesItself reports whether the job may leave the scheduler without going through the server, such as when
+// gocron removes it once it has no runs left. The options of a job registered in code cannot be inspected, it may
+// have limited runs or a stop time, and the code may remove it from the scheduler directly.
+func (mj *managedJob) removesItself() bool {
+	if mj.spec.Type == ScheduleOneTime || mj.request == nil {
+		return true
+	}
+	return mj.request.Options != nil && (mj.request.Options.LimitedRuns > 0 || mj.request.Options.StopAt != "")
+}
+
+// pruneJobs forgets the jobs which left the scheduler on their own, such as one-time jobs after their last run.
+// Other jobs created through the API are kept, the scheduler does not list any jobs once it was shut down.
+// The metrics release the series of job names which no job has anymore.
+func (s *Server) pruneJobs() {
+	// the lock is held while listing the jobs so that a job which is being registered is not mistaken for a removed one
+	s.jobsMutex.Lock()
+	scheduled := make(map[uuid.UUID]bool)
+	names := make(map[string]bool) // of the jobs which still exist
+	for _, job := range s.Scheduler.Jobs() {
+		scheduled[job.ID()] = true
+		names[job.Name()] = true
+	}
+	var removed []uuid.UUID
+	for id, mj := range s.jobs {
+		if mj.paused == nil && mj.removesItself() && !scheduled[id] {
+			delete(s.jobs, id)
+			removed = append(removed, id)
+		} else {
+			names[mj.name] = true
+		}
+	}
+	s.jobsMutex.Unlock()
+
+	for _, id := range removed {
+		s.resetRunCount(id)
+		s.forgetJob(id)
+		if s.monitor != nil {
+			s.monitor.untrackTask(id, false)
+		}
+	}
+	if s.monitor != nil {
+		s.monitor.dropRemoved(scheduled)
+	}
+	if s.metrics != nil {
+		// a run in progress is counted once it finished
+		if s.monitor != nil {
+			for _, run := range s.monitor.Running() {
+				names[run.JobName] = true
+			}
+		}
+		s.metrics.releaseJobs(names)
+	}
+}
+
+// createJob adds the job described by a request to the scheduler and persists it when a store is configured.
+// A non-nil id recreates a persisted job under its original ID.
+func (s *Server) createJob(req CreateJobRequest, id uuid.UUID) (gocron.Job, error) {
+	errs := fieldErrors{}
+	if req.Name == "" {
+		errs["name"] = "Job name is required"
+	}
+	spec, err := requestSpec(req)
+	if err := errs.merge(err); err != nil {
+		return nil, err
+	}
+	task, err := s.jobTask(req)
+	if err := errs.merge(err); err != nil {
+		return nil, err
+	}
+	restored := id != uuid.Nil
+	effective := req.Options
+	if restored {
+		// the job ran before the restart, it is not started right away again and keeps the runs it has left
+		var ok bool
+		if effective, ok = req.Options.remaining(s.runCount(id)); !ok {
+			return nil, errNoRunsLeft
+		}
+	}
+	requestOptions, err := effective.gocronOptions(time.Now())
+	if err := errs.merge(err); err != nil {
+		return nil, err
+	}
+	if !restored {
+		// restored jobs may have started already, new ones must not start in the past
+		if req.Options != nil && req.Options.StartAt != "" && errs["options.startAt"] == "" {
+			if t, _ := time.Parse(time.RFC3339, req.Options.StartAt); !t.After(time.Now()) {
+				errs["options.startAt"] = "Start time must be in the future"
+			}
+		}
+		if requested := req.Options.identifier(); requested != uuid.Nil && s.jobExists(requested) {
+			errs["options.identifier"] = "A job with this identifier already exists"
+		}
+	}
+	if err := errs.errOrNil(); err != nil {
+		return nil, err
+	}
+
+	// create job options
+	options := []gocron.JobOption{
+		gocron.WithName(req.Name),
+	}
+	if len(req.Tags) > 0 {
+		options = append(options, gocron.WithTags(req.Tags...))
+	}
+	options = append(options, requestOptions...)
+
+	// the ID is chosen up front so that the job's task knows it from the first run on
+	if id == uuid.Nil {
+		id = req.Options.identifier()
+	}
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	options = append(options, gocron.WithIdentifier(id))
+
+	// add job to scheduler
+	job, err := s.registerJob(id, spec, task, options, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !restored {
+		s.persistJob(job.ID(), req)
+	}
+	return job, nil
+}
+
+// jobTask creates the task of a job created through the API
+func (s *Server) jobTask(req CreateJobRequest) (Task, error) {
+	if req.Task == "" {
+		if len(req.Params) > 0 {
+			return Task{}, invalidField("params", "Params require a task")
+		}
+		// without a registered task the job only logs its name
+		return NewTask(func() {
+			log.Printf("Executing job: %s", req.Name)
+		}), nil
+	}
+
+	fn, params, err := s.tasks.bind(req.Task, req.Params)
+	if err != nil {
+		return Task{}, err
+	}
+	return NewTask(func(ctx context.Context) error {
+		// every run gets its own copy so that a task cannot leak changes into the next run
+		runParams := make(Params, len(params))
+		for name, value := range params {
+			runParams[name] = value
+		}
+		return fn(ctx, runParams)
+	}), nil
+}
+
+// UpdateJob replaces the definition of a job with the request body, keeping the job's ID.
+// The job keeps its current task if the request does not name one, and its options and the runs it has left
+// if the request has no options.
+func (s *Server) UpdateJob(w http.ResponseWriter, r *http.Request) {
+	s.updateJob(w, r, false)
+}
+
+// PatchJob changes the fields of a job's definition which are present in the request body, keeping the job's ID.
+// The params are merged into the current ones, unless the body names a task, then they replace them.
+func (s *Server) PatchJob(w http.ResponseWriter, r *http.Request) {
+	s.updateJob(w, r, true)
+}
+
+func (s *Server) updateJob(w http.ResponseWriter, r *http.Request, partial bool) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	// the definition is read and replaced under the job's lock, so that concurrent changes are not lost
+	unlock := s.lockJob(id)
+	defer unlock()
+
+	mj, ok := s.managedJob(id)
+	if !ok {
+		if _, found := s.findJob(id); found {
+			respondError(w, http.StatusConflict, "Job was registered directly on the scheduler and cannot be updated")
+			return
+		}
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(body, &present); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// a patch is applied on top of the job's current definition
+	var req CreateJobRequest
+	if partial {
+		req = mj.currentRequest()
+		if _, ok := present["task"]; ok {
+			// the params of another task do not apply
+			req.Params = nil
+		}
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, ok := present["options"]; !ok && !partial && mj.request != nil {
+		// a limited job does not become unlimited because the options were left out
+		req.Options = mj.request.Options.copy()
+	}
+	if partial {
+		// atTime is the older form of atTimes, whichever a patch sets replaces the other
+		_, hasAtTime := present["atTime"]
+		_, hasAtTimes := present["atTimes"]
+		if hasAtTimes && !hasAtTime {
+			req.AtTime = ""
+		} else if hasAtTime && !hasAtTimes {
+			req.AtTimes = nil
+		}
+	}
+	if !s.authorizeTags(w, r, req.Tags) {
+		return
+	}
+
+	if err := s.applyUpdate(id, mj, req, present, partial); err != nil {
+		respondJobError(w, err)
+		return
+	}
+
+	jobData, _ := s.jobData(id)
+	respondJSON(w, http.StatusOK, jobData)
+}
+
+// applyUpdate validates the updated request of a job and replaces the job's definition in the scheduler.
+// A paused job only has its definition replaced, it is registered with it once it is resumed.
+func (s *Server) applyUpdate(id uuid.UUID, mj *managedJob, req CreateJobRequest, present map[string]json.RawMessage, partial bool) error {
+	errs := fieldErrors{}
+	if req.Name == "" {
+		errs["name"] = "Job name is required"
+	}
+
+	// the schedule is only rebuilt when it changes, so that patching the name of
+	// a job registered in code does not require restating its schedule
+	spec := mj.spec
+	if !partial || hasAnyField(present, scheduleFields) {
+		if partial && mj.request == nil && present["type"] == nil {
+			errs["type"] = "Type is required to change the schedule of a job registered in code"
+		} else {
+			var err error
+			spec, err = requestSpec(req)
+			if err := errs.merge(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	// jobs created through the API get their task rebuilt from the request, a job registered
+	// in code keeps its function unless it is switched to a registered task
+	task := mj.task
+	taskChanged := hasAnyField(present, []string{"task", "params"})
+	if mj.request != nil {
+		if !taskChanged {
+			req.Task, req.Params = mj.request.Task, mj.request.Params
+		} else if req.Task == "" {
+			req.Task = mj.request.Task
+		}
+		var err error
+		task, err = s.jobTask(req)
+		if err := errs.merge(err); err != nil {
+			return err
+		}
+	} else if taskChanged {
+		if req.Task == "" {
+			errs["task"] = "Task is required to change the task of a job registered in code"
+		} else {
+			var err error
+			task, err = s.jobTask(req)
+			if err := errs.merge(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	// jobs created through the API get their options rebuilt as well, the options of a job registered in code are unknown
+	options := mj.options
+	if mj.request != nil {
+		if requested := req.Options.identifier(); requested != uuid.Nil && requested != id {
+			errs["options.identifier"] = "Identifier cannot be changed"
+		}
+		effective := req.Options
+		if _, ok := present["options"]; !ok {
+			// an update which does not touch the options does not start the job right away again
+			// and keeps the runs it has left
+			var ok bool
+			if effective, ok = req.Options.remaining(s.runCount(id)); !ok {
+				return errNoRunsLeft
+			}
+		}
+		var err error
+		options, err = effective.gocronOptions(time.Now())
+		if err := errs.merge(err); err != nil {
+			return err
+		}
+	} else if _, ok := present["options"]; ok {
+		errs["options"] = "Options of a job registered in code cannot be changed"
+	}
+
+	if err := errs.errOrNil(); err != nil {
+		return err
+	}
+
+	jobDef, err := spec.definition()
+	if err != nil {
+		return err
+	}
+
+	updated := &managedJob{
+		spec:    spec,
+		task:    task,
+		options: options,
+		name:    req.Name,
+		tags:    req.Tags,
+		paused:  mj.paused,
+		lastRun: mj.lastRun,
+	}
+	if mj.request != nil {
+		updated.request = &req
+	}
+
+	if updated.paused == nil {
+		options := updated.jobOptions()
+		if updated.request == nil {
+			// like a resumed job, the job is not started right away again and keeps the runs it has left
+			if options, err = codeJobOptions(options, s.runCount(id), time.Now()); err != nil {
+				return err
+			}
+		}
+		if _, err := s.Scheduler.Update(id, jobDef, s.gocronTask(task, newJobRef(id)), options...); err != nil {
+			return schedulerError(err)
+		}
+	}
+
+	s.jobsMutex.Lock()
+	s.jobs[id] = updated
+	s.jobsMutex.Unlock()
+	if _, ok := present["options"]; ok && updated.request != nil {
+		// the job starts over with its new options
+		s.resetRunCount(id)
+	}
+
+	if updated.request != nil {
+		s.persistJob(id, req)
+	}
+	s.notifyJobsChanged()
+	return nil
+}
+
+func hasAnyField(present map[string]json.RawMessage, fields []string) bool {
+	for _, field := range fields {
+		if _, ok := present[field]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+// newAPIServer creates a server on a started scheduler with a task "noop" for jobs created through the API
+func newAPIServer(t *testing.T) *Server {
+	t.Helper()
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+
+	s, err := NewServer(scheduler, 0, WithTask("noop", func(context.Context, Params) error { return nil }, TaskSchema{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	return s
+}
+
+func serve(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+// createJob creates a job through the API and returns its ID
+func createJob(t *testing.T, s *Server, body string) string {
+	t.Helper()
+	rec := serve(s, http.MethodPost, "/api/jobs", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body.String())
+	}
+	var job JobData
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	return job.ID
+}
+
+func parseID(t *testing.T, id string) uuid.UUID {
+	t.Helper()
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// gocronLimit returns the limit of runs the job is registered with in gocron, 0 for none
+func gocronLimit(t *testing.T, s *Server, id string) uint {
+	t.Helper()
+	mj, ok := s.managedJob(parseID(t, id))
+	if !ok {
+		t.Fatalf("job %s is not managed", id)
+	}
+	var limit uint
+	for _, option := range mj.options {
+		if effect, _ := inspectOption(option, time.Now()); effect.limitedRuns > 0 {
+			limit = effect.limitedRuns
+		}
+	}
+	return limit
+}
+
+func TestUpdateJobOptions(t *testing.T) {
+	const job = `"name":"report","type":"duration","interval":3600,"task":"noop"`
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+		limit  uint // limitedRuns of the job's options
+		runs   uint // runs counted against them
+		gocron uint // runs left in gocron
+	}{
+		{name: "put without options", method: http.MethodPut, body: `{` + job + `}`, want: http.StatusOK, limit: 3, runs: 1, gocron: 2},
+		{name: "put with options", method: http.MethodPut, body: `{` + job + `,"options":{"limitedRuns":5}}`, want: http.StatusOK, limit: 5, runs: 0, gocron: 5},
+		{name: "put without a limit", method: http.MethodPut, body: `{` + job + `,"options":{}}`, want: http.StatusOK},
+		{name: "patch without options", method: http.MethodPatch, body: `{"name":"renamed"}`, want: http.StatusOK, limit: 3, runs: 1, gocron: 2},
+		{name: "patch with options", method: http.MethodPatch, body: `{"options":{"limitedRuns":4}}`, want: http.StatusOK, limit: 4, runs: 0, gocron: 4},
+		{name: "patch without a limit", method: http.MethodPatch, body: `{"options":null}`, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAPIServer(t)
+			id := createJob(t, s, `{`+job+`,"options":{"limitedRuns":3}}`)
+			s.countRun(parseID(t, id))
+
+			rec := serve(s, tt.method, "/api/jobs/"+id, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			var got JobData
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			var limit uint
+			if got.Options != nil {
+				limit = got.Options.LimitedRuns
+			}
+			if limit != tt.limit {
+				t.Errorf("limitedRuns = %d, want %d", limit, tt.limit)
+			}
+			if runs := s.runCount(parseID(t, id)); runs != tt.runs {
+				t.Errorf("runs = %d, want %d", runs, tt.runs)
+			}
+			if left := gocronLimit(t, s, id); left != tt.gocron {
+				t.Errorf("gocron limit = %d, want %d", left, tt.gocron)
+			}
+		})
+	}
+}
+
+func TestUpdateJobWithoutRunsLeft(t *testing.T) {
+	s := newAPIServer(t)
+	id := createJob(t, s, `{"name":"report","type":"duration","interval":3600,"task":"noop","options":{"limitedRuns":2}}`)
+	s.countRun(parseID(t, id))
+	s.countRun(parseID(t, id))
+
+	// leaving the options out does not give the job its runs back
+	for method, body := range map[string]string{
+		http.MethodPut:   `{"name":"report","type":"duration","interval":60}`,
+		http.MethodPatch: `{"interval":60}`,
+	} {
+		if rec := serve(s, method, "/api/jobs/"+id, body); rec.Code != http.StatusConflict {
+			t.Errorf("%s = %d, want %d: %s", method, rec.Code, http.StatusConflict, rec.Body.String())
+		}
+	}
+	// new options start over
+	body := `{"name":"report","type":"duration","interval":60,"options":{"limitedRuns":2}}`
+	if rec := serve(s, http.MethodPut, "/api/jobs/"+id, body); rec.Code != http.StatusOK {
+		t.Errorf("PUT with options = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUpdateJobKeepsTask(t *testing.T) {
+	s := newAPIServer(t)
+	id := createJob(t, s, `{"name":"report","type":"duration","interval":3600,"task":"noop"}`)
+
+	rec := serve(s, http.MethodPut, "/api/jobs/"+id, `{"name":"renamed","type":"cron","cronExpression":"0 * * * *"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	mj, _ := s.managedJob(parseID(t, id))
+	if mj.name != "renamed" || mj.spec.Type != ScheduleCron || mj.request.Task != "noop" {
+		t.Errorf("definition = %+v, want the new name and schedule with the task noop", mj.request)
+	}
+
+	// a patch only changes what it names
+	rec = serve(s, http.MethodPatch, "/api/jobs/"+id, `{"tags":["ops"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	mj, _ = s.managedJob(parseID(t, id))
+	if mj.name != "renamed" || mj.spec.CronExpression != "0 * * * *" || len(mj.tags) != 1 || mj.tags[0] != "ops" {
+		t.Errorf("definition after patch = %+v", mj.request)
+	}
+}
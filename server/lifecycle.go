@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// shutdownTimeout limits the graceful shutdown when the context of ListenAndServe is cancelled
+	shutdownTimeout = 10 * time.Second
+	// readHeaderTimeout limits how long clients of ListenAndServe may take to send the request headers
+	readHeaderTimeout = 10 * time.Second
+)
+
+// WithSchedulerShutdown makes Shutdown shut the scheduler down once the server stopped serving,
+// for applications which leave the whole lifecycle to the server
+func WithSchedulerShutdown() Option {
+	return func(s *Server) {
+		s.shutdownScheduler = true
+	}
+}
+
+// ListenAndServe serves the UI and the API on the port given to NewServer until the context is cancelled,
+// then shuts the server down as Shutdown does. It also returns once Shutdown is called, Shutdown returns
+// when the shutdown is complete.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           s.Router,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	s.lifecycleMutex.Lock()
+	if s.stopped {
+		s.lifecycleMutex.Unlock()
+		return http.ErrServerClosed
+	}
+	s.httpServer = srv
+	s.lifecycleMutex.Unlock()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+// Shutdown stops the server gracefully. It stops listening if the server was started with ListenAndServe,
+// closes the WebSocket clients with a close frame and the event streams, stops the background goroutines and
+// waits for the requests in flight. Then it shuts the scheduler down if WithSchedulerShutdown was given.
+// It returns the error of the context if that takes too long, the server cannot be started again.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lifecycleMutex.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.done)
+	}
+	srv := s.httpServer
+	s.lifecycleMutex.Unlock()
+
+	// event streams are requests in flight, they have to end before the HTTP server can shut down
+	s.clientsMu.Lock()
+	s.stopping = true
+	for c := range s.clients {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+	}
+	s.clientsMu.Unlock()
+
+	var errs []error
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := waitContext(ctx, &s.background); err != nil {
+		errs = append(errs, fmt.Errorf("waiting for clients and background goroutines: %w", err))
+	}
+
+	if s.shutdownScheduler {
+		s.schedulerOnce.Do(func() {
+			if err := s.Scheduler.Shutdown(); err != nil {
+				errs = append(errs, fmt.Errorf("shutting down scheduler: %w", err))
+			}
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// goBackground runs fn in a goroutine which Shutdown waits for, fn has to return once s.done is closed
+func (s *Server) goBackground(fn func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
+}
+
+// waitContext waits for a wait group or until the context is done
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
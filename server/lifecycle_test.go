@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdownStopsBackground(t *testing.T) {
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	s, err := NewServer(scheduler, 0,
+		WithNotifier("test", notifierFunc(func(context.Context, Alert) error { return nil })),
+		WithAlertRules(AlertRule{Name: "failures", Condition: AlertOnConsecutiveFailures, Count: 1}),
+		WithSchedulerShutdown(),
+	)
+	if err != nil {
+		_ = scheduler.Shutdown()
+		t.Fatal(err)
+	}
+	// the server shuts the scheduler down only once, gocron times out when it is shut down twice
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	if _, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("idle")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a WebSocket client and an event stream count as background work until they are closed
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resp, err := http.Get(ts.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := waitContext(ctx, &s.background); err != nil {
+		t.Errorf("background goroutines still running: %v", err)
+	}
+
+	// the WebSocket client is told why it is disconnected
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("WebSocket closed with %v, want going away", err)
+	}
+	// gocron lists no jobs once it was shut down
+	if jobs := scheduler.Jobs(); len(jobs) != 0 {
+		t.Errorf("the scheduler was not shut down, it lists %d jobs", len(jobs))
+	}
+	if err := s.ListenAndServe(context.Background()); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("ListenAndServe after Shutdown = %v", err)
+	}
+}
+
+func TestListenAndServeStopsWithContext(t *testing.T) {
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+	s, err := NewServer(scheduler, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServe(ctx) }()
+
+	// wait until the server listens, so that the cancellation shuts down a running HTTP server
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.lifecycleMutex.Lock()
+		listening := s.httpServer != nil
+		s.lifecycleMutex.Unlock()
+		if listening || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ListenAndServe = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe did not return once its context was cancelled")
+	}
+
+	wait, stop := context.WithTimeout(context.Background(), time.Second)
+	defer stop()
+	if err := waitContext(wait, &s.background); err != nil {
+		t.Errorf("background goroutines still running: %v", err)
+	}
+}
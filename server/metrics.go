@@ -0,0 +1,453 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// default bounds of the metrics
+var (
+	// DefaultJobDurationBuckets are the upper bounds of the job duration histogram in seconds
+	DefaultJobDurationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+	// DefaultHTTPDurationBuckets are the upper bounds of the HTTP request duration histogram in seconds
+	DefaultHTTPDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+)
+
+// DefaultMaxJobSeries is the number of distinct job names the metrics keep series for
+const DefaultMaxJobSeries = 500
+
+// otherJobs is the job_name label of all jobs beyond MetricsConfig.MaxJobs
+const otherJobs = "_other"
+
+// MetricsConfig configures the Prometheus metrics endpoint
+type MetricsConfig struct {
+	// Path of the endpoint, /metrics by default
+	Path string
+	// Public serves the metrics without authentication, for scrapers inside a trusted network
+	Public bool
+	// Tags are the job tags which are exported in the tags label, other tags are left out so that
+	// the number of series stays bounded. No tags label is exported if empty.
+	Tags []string
+	// MaxJobs bounds the number of job names with their own series, further jobs are counted as job_name="_other".
+	// The series of a name are dropped once no job has it anymore. Zero falls back to DefaultMaxJobSeries.
+	MaxJobs int
+	// JobDurationBuckets are the buckets of the job duration histogram in seconds, DefaultJobDurationBuckets if empty
+	JobDurationBuckets []float64
+	// HTTPDurationBuckets are the buckets of the HTTP request duration histogram in seconds, DefaultHTTPDurationBuckets if empty
+	HTTPDurationBuckets []float64
+}
+
+// WithMetrics serves Prometheus metrics about the jobs and the server itself.
+// Runs are only counted with a monitor, see WithMonitor.
+func WithMetrics(cfg MetricsConfig) Option {
+	return func(s *Server) {
+		if cfg.Path == "" {
+			cfg.Path = "/metrics"
+		}
+		if cfg.MaxJobs <= 0 {
+			cfg.MaxJobs = DefaultMaxJobSeries
+		}
+		if len(cfg.JobDurationBuckets) == 0 {
+			cfg.JobDurationBuckets = DefaultJobDurationBuckets
+		}
+		if len(cfg.HTTPDurationBuckets) == 0 {
+			cfg.HTTPDurationBuckets = DefaultHTTPDurationBuckets
+		}
+		s.metrics = newMetrics(cfg)
+	}
+}
+
+// jobSeries identifies the series of a job
+type jobSeries struct {
+	job  string
+	tags string
+}
+
+// runSeries identifies the run counter of a job
+type runSeries struct {
+	jobSeries
+	status string
+}
+
+// httpSeries identifies the request histogram of a route
+type httpSeries struct {
+	route  string
+	method string
+	code   string
+}
+
+// histogram counts observations in cumulative buckets
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative, the last one counts everything above the highest bound
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// metrics collects the metrics which are counted as things happen, the others are read when scraped
+type metrics struct {
+	cfg MetricsConfig
+
+	mu          sync.Mutex
+	jobs        map[string]bool // job names with their own series
+	runs        map[runSeries]uint64
+	durations   map[jobSeries]*histogram
+	lastSuccess map[jobSeries]time.Time
+	requests    map[httpSeries]*histogram
+}
+
+func newMetrics(cfg MetricsConfig) *metrics {
+	cfg.JobDurationBuckets = slices.Sorted(slices.Values(cfg.JobDurationBuckets))
+	cfg.HTTPDurationBuckets = slices.Sorted(slices.Values(cfg.HTTPDurationBuckets))
+	return &metrics{
+		cfg:         cfg,
+		jobs:        make(map[string]bool),
+		runs:        make(map[runSeries]uint64),
+		durations:   make(map[jobSeries]*histogram),
+		lastSuccess: make(map[jobSeries]time.Time),
+		requests:    make(map[httpSeries]*histogram),
+	}
+}
+
+// series returns the labels of a job, m.mu must be held
+func (m *metrics) series(name string, tags []string) jobSeries {
+	if !m.jobs[name] {
+		if len(m.jobs) >= m.cfg.MaxJobs {
+			name = otherJobs
+		} else {
+			m.jobs[name] = true
+		}
+	}
+
+	var exported []string
+	for _, tag := range tags {
+		if slices.Contains(m.cfg.Tags, tag) && !slices.Contains(exported, tag) {
+			exported = append(exported, tag)
+		}
+	}
+	sort.Strings(exported)
+	return jobSeries{job: name, tags: strings.Join(exported, ",")}
+}
+
+// releaseJobs drops the series of the job names which are not in use anymore, so that they do not take
+// the place of new jobs under MaxJobs
+func (m *metrics) releaseJobs(names map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	released := make(map[string]bool)
+	for name := range m.jobs {
+		if !names[name] {
+			delete(m.jobs, name)
+			released[name] = true
+		}
+	}
+	if len(released) == 0 {
+		return
+	}
+	maps.DeleteFunc(m.runs, func(k runSeries, _ uint64) bool { return released[k.job] })
+	maps.DeleteFunc(m.durations, func(k jobSeries, _ *histogram) bool { return released[k.job] })
+	maps.DeleteFunc(m.lastSuccess, func(k jobSeries, _ time.Time) bool { return released[k.job] })
+}
+
+// observeRun counts a finished run
+func (m *metrics) observeRun(run JobRun, tags []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series := m.series(run.JobName, tags)
+	m.runs[runSeries{jobSeries: series, status: run.Status}]++
+
+	h, ok := m.durations[series]
+	if !ok {
+		h = newHistogram(m.cfg.JobDurationBuckets)
+		m.durations[series] = h
+	}
+	h.observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+
+	if run.Status == RunStatusSuccess && run.FinishedAt.After(m.lastSuccess[series]) {
+		m.lastSuccess[series] = run.FinishedAt
+	}
+}
+
+// observeRequest times a request
+func (m *metrics) observeRequest(series httpSeries, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.requests[series]
+	if !ok {
+		h = newHistogram(m.cfg.HTTPDurationBuckets)
+		m.requests[series] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// statusRecorder captures the status of a response for the request metrics and spans
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// instrument is a mux middleware which times the requests of every route by its path template,
+// which keeps the number of series bounded. WebSocket connections and event streams are long-lived and not timed.
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStreaming(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		s.metrics.observeRequest(httpSeries{route: route, method: r.Method, code: strconv.Itoa(rec.status)}, time.Since(start))
+	})
+}
+
+// GetMetrics serves the metrics in the Prometheus text format
+func (s *Server) GetMetrics(w http.ResponseWriter, _ *http.Request) {
+	m := s.metrics
+
+	// the state of the jobs is read from the scheduler and the monitor as it is now
+	type jobState struct {
+		name    string
+		tags    []string
+		next    time.Time
+		running int
+	}
+	jobs := s.Scheduler.Jobs()
+	states := make([]jobState, 0, len(jobs))
+	for _, job := range jobs {
+		state := jobState{name: job.Name(), tags: job.Tags()}
+		if next, err := job.NextRun(); err == nil {
+			state.next = next
+		}
+		if s.monitor != nil {
+			state.running = len(s.monitor.runningOf(job.ID()))
+		}
+		states = append(states, state)
+	}
+
+	nextRuns := make(map[jobSeries]time.Time)
+	running := make(map[jobSeries]int)
+	m.mu.Lock()
+	for _, state := range states {
+		series := m.series(state.name, state.tags)
+		// jobs sharing a series report the earliest next run and their executions together
+		if current, ok := nextRuns[series]; !state.next.IsZero() && (!ok || state.next.Before(current)) {
+			nextRuns[series] = state.next
+		}
+		running[series] += state.running
+	}
+	m.mu.Unlock()
+	paused := len(s.pausedJobsData())
+
+	clients := make(map[string]int)
+	s.clientsMu.RLock()
+	for c := range s.clients {
+		clients[c.transport]++
+	}
+	s.clientsMu.RUnlock()
+
+	var b strings.Builder
+
+	m.mu.Lock()
+	writeFamily(&b, "gocron_ui_job_runs_total", "counter", "Finished runs of a job by status (success, failed, cancelled).")
+	for _, key := range sortedKeys(m.runs, func(k runSeries) string { return k.job + "\x00" + k.tags + "\x00" + k.status }) {
+		writeSample(&b, "gocron_ui_job_runs_total", m.jobLabels(key.jobSeries, "status", key.status), float64(m.runs[key]))
+	}
+
+	writeFamily(&b, "gocron_ui_job_duration_seconds", "histogram", "Duration of the finished runs of a job.")
+	for _, key := range sortedKeys(m.durations, jobSeries.String) {
+		writeHistogram(&b, "gocron_ui_job_duration_seconds", m.jobLabels(key), m.durations[key])
+	}
+
+	writeFamily(&b, "gocron_ui_job_last_success_timestamp_seconds", "gauge", "Time the last successful run of a job finished.")
+	for _, key := range sortedKeys(m.lastSuccess, jobSeries.String) {
+		writeSample(&b, "gocron_ui_job_last_success_timestamp_seconds", m.jobLabels(key), unixSeconds(m.lastSuccess[key]))
+	}
+
+	writeFamily(&b, "gocron_ui_job_next_run_timestamp_seconds", "gauge", "Time a job runs next.")
+	for _, key := range sortedKeys(nextRuns, jobSeries.String) {
+		writeSample(&b, "gocron_ui_job_next_run_timestamp_seconds", m.jobLabels(key), unixSeconds(nextRuns[key]))
+	}
+
+	writeFamily(&b, "gocron_ui_job_running", "gauge", "Executions of a job in progress.")
+	for _, key := range sortedKeys(running, jobSeries.String) {
+		writeSample(&b, "gocron_ui_job_running", m.jobLabels(key), float64(running[key]))
+	}
+
+	writeFamily(&b, "gocron_ui_http_request_duration_seconds", "histogram", "Duration of HTTP requests by route, method and status code.")
+	for _, key := range sortedKeys(m.requests, func(k httpSeries) string { return k.route + "\x00" + k.method + "\x00" + k.code }) {
+		writeHistogram(&b, "gocron_ui_http_request_duration_seconds", []string{"route", key.route, "method", key.method, "code", key.code}, m.requests[key])
+	}
+	m.mu.Unlock()
+
+	writeFamily(&b, "gocron_ui_jobs", "gauge", "Jobs by state.")
+	writeSample(&b, "gocron_ui_jobs", []string{"state", "scheduled"}, float64(len(states)))
+	writeSample(&b, "gocron_ui_jobs", []string{"state", "paused"}, float64(paused))
+
+	writeFamily(&b, "gocron_ui_scheduler_running", "gauge", "Whether the scheduler is started (1) or was stopped through the API (0).")
+	writeSample(&b, "gocron_ui_scheduler_running", nil, boolFloat(!s.schedulerStopped.Load()))
+
+	writeFamily(&b, "gocron_ui_websocket_clients", "gauge", "Connected WebSocket clients.")
+	writeSample(&b, "gocron_ui_websocket_clients", nil, float64(clients[transportWebSocket]))
+	writeFamily(&b, "gocron_ui_sse_clients", "gauge", "Connected server-sent events clients.")
+	writeSample(&b, "gocron_ui_sse_clients", nil, float64(clients[transportSSE]))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func (k jobSeries) String() string {
+	return k.job + "\x00" + k.tags
+}
+
+// jobLabels returns the labels of a job series followed by extra label pairs
+func (m *metrics) jobLabels(k jobSeries, extra ...string) []string {
+	labels := []string{"job_name", k.job}
+	if len(m.cfg.Tags) > 0 {
+		labels = append(labels, "tags", k.tags)
+	}
+	return append(labels, extra...)
+}
+
+func sortedKeys[K comparable, V any](values map[K]V, key func(K) string) []K {
+	keys := make([]K, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return key(keys[i]) < key(keys[j])
+	})
+	return keys
+}
+
+func writeFamily(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes a sample with labels given as name, value pairs
+func writeSample(b *strings.Builder, name string, labels []string, value float64) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+}
+
+func writeHistogram(b *strings.Builder, name string, labels []string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		writeSample(b, name+"_bucket", append(slices.Clone(labels), "le", formatFloat(bound)), float64(cumulative))
+	}
+	writeSample(b, name+"_bucket", append(slices.Clone(labels), "le", "+Inf"), float64(h.count))
+	writeSample(b, name+"_sum", labels, h.sum)
+	writeSample(b, name+"_count", labels, float64(h.count))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func boolFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
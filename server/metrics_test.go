@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func TestMetricsReleaseRemovedJobs(t *testing.T) {
+	s, _ := newMonitoredServer(t, WithMetrics(MetricsConfig{MaxJobs: 1}))
+	scrape := func() string {
+		t.Helper()
+		rec := serve(s, http.MethodGet, "/metrics", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("metrics = %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	first, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.metrics.observeRun(JobRun{JobName: "first", Status: RunStatusSuccess, StartedAt: now, FinishedAt: now}, nil)
+	if _, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	body := scrape()
+	if !strings.Contains(body, `job_name="first"`) || !strings.Contains(body, `job_name="_other"`) || strings.Contains(body, `job_name="second"`) {
+		t.Fatalf("the second job does not share _other:\n%s", body)
+	}
+
+	// the name of a deleted job is free for the jobs left
+	if rec := serve(s, http.MethodDelete, "/api/jobs/"+first.ID().String(), ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	s.pruneJobs()
+	body = scrape()
+	if strings.Contains(body, `job_name="first"`) {
+		t.Errorf("the deleted job still has series:\n%s", body)
+	}
+	if !strings.Contains(body, `gocron_ui_job_next_run_timestamp_seconds{job_name="second"}`) {
+		t.Errorf("the second job did not get its own series:\n%s", body)
+	}
+}
@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+// maxPendingRecords bounds how many runs the monitor buffers before it is attached to a server
+const maxPendingRecords = 1000
+
+// Monitor implements gocron.MonitorStatus and feeds every job execution into the run history of a server.
+// gocron only accepts monitors when the scheduler is created, so the same monitor has to be passed to both
+// the scheduler and the server:
+//
+//	monitor := server.NewMonitor()
+//	scheduler, _ := gocron.NewScheduler(monitor.SchedulerOptions()...)
+//	srv, err := server.NewServer(scheduler, 8080, server.WithMonitor(monitor))
+//
+// The tasks of jobs registered through the server report when they start running. For jobs registered directly
+// on the scheduler, SchedulerOptions installs a global BeforeJobRuns listener besides WithMonitorStatus.
+type Monitor struct {
+	mu      sync.Mutex
+	server  *Server
+	pending []runRecord
+	running map[uuid.UUID][]ActiveRun // oldest first
+	tasks   map[uuid.UUID]bool        // jobs whose tasks report their starts, the listener ignores them
+	// reported is true for the jobs whose start the listener reported, and false for the jobs which finished
+	// a run without and were logged
+	reported map[uuid.UUID]bool
+}
+
+var _ gocron.MonitorStatus = (*Monitor)(nil)
+
+// runRecord is a finished execution as reported by gocron
+type runRecord struct {
+	jobID     uuid.UUID
+	jobName   string
+	tags      []string
+	startedAt time.Time
+	endedAt   time.Time
+	err       error
+	run       *ActiveRun // the tracked execution, if its start was reported
+}
+
+// NewMonitor creates a monitor which can be shared between a scheduler and a server
+func NewMonitor() *Monitor {
+	return &Monitor{
+		running:  make(map[uuid.UUID][]ActiveRun),
+		tasks:    make(map[uuid.UUID]bool),
+		reported: make(map[uuid.UUID]bool),
+	}
+}
+
+// SchedulerOptions returns the options which connect a scheduler to the monitor: gocron.WithMonitorStatus and
+// gocron.WithGlobalJobOptions with a BeforeJobRuns listener. The listener is only needed for jobs registered
+// directly on the scheduler. gocron keeps only one global option list and one listener of each kind per job,
+// so schedulers which need their own global options or such jobs with their own BeforeJobRuns listener have
+// to call Monitor.BeforeJobRuns from their listener instead, the server logs jobs which never reported a start.
+func (m *Monitor) SchedulerOptions() []gocron.SchedulerOption {
+	return []gocron.SchedulerOption{
+		gocron.WithMonitorStatus(m),
+		gocron.WithGlobalJobOptions(gocron.WithEventListeners(gocron.BeforeJobRuns(m.BeforeJobRuns))),
+	}
+}
+
+// BeforeJobRuns records that a job started running, it has the signature of a gocron.BeforeJobRuns listener.
+// gocron calls it before a BeforeJobRunsSkipIfBeforeFuncErrors listener may still skip the run, a skipped
+// run of a job registered directly on the scheduler is listed as running until the job is removed.
+func (m *Monitor) BeforeJobRuns(id uuid.UUID, name string) {
+	m.startRun(id, name, false)
+}
+
+// taskStarted records that the task of a job registered through the server started running
+func (m *Monitor) taskStarted(id uuid.UUID, name string) {
+	m.startRun(id, name, true)
+}
+
+// startRun records a started execution if it is reported by the side which tracks the job's runs
+func (m *Monitor) startRun(id uuid.UUID, name string, byTask bool) {
+	run := ActiveRun{
+		ID:        uuid.NewString(),
+		JobID:     id.String(),
+		JobName:   name,
+		StartedAt: time.Now(),
+		Trigger:   TriggerScheduled,
+	}
+
+	m.mu.Lock()
+	if m.tasks[id] != byTask {
+		m.mu.Unlock()
+		return
+	}
+	if !byTask {
+		m.reported[id] = true
+	}
+	s := m.server
+	if s != nil && s.takeManualRun(id) {
+		run.Trigger = TriggerManual
+	}
+	m.running[id] = append(m.running[id], run)
+	m.mu.Unlock()
+
+	if s != nil {
+		tags, _ := s.jobTags(id)
+		s.events.runStarted(run, tags)
+		s.notifyJobsChanged()
+	}
+}
+
+// trackTask makes the task of a job report its starts instead of the BeforeJobRuns listener
+func (m *Monitor) trackTask(id uuid.UUID) {
+	m.mu.Lock()
+	m.tasks[id] = true
+	m.mu.Unlock()
+}
+
+// untrackTask hands the starts of a job back to the BeforeJobRuns listener. With dropRunning the executions
+// the task recorded are dropped as well, for a job which was registered under another ID.
+func (m *Monitor) untrackTask(id uuid.UUID, dropRunning bool) {
+	m.mu.Lock()
+	delete(m.tasks, id)
+	if dropRunning {
+		delete(m.running, id)
+	}
+	m.mu.Unlock()
+}
+
+// dropRemoved forgets the executions recorded by the listener for jobs which are no longer scheduled,
+// such as runs which a BeforeJobRunsSkipIfBeforeFuncErrors listener skipped
+func (m *Monitor) dropRemoved(scheduled map[uuid.UUID]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.running {
+		if !scheduled[id] && !m.tasks[id] {
+			delete(m.running, id)
+		}
+	}
+	for id := range m.reported {
+		if !scheduled[id] {
+			delete(m.reported, id)
+		}
+	}
+}
+
+// checkReported logs a job registered directly on the scheduler the first time it finishes a run without
+// having reported its start, its BeforeJobRuns listener replaced the monitor's
+func (m *Monitor) checkReported(id uuid.UUID, name string) {
+	m.mu.Lock()
+	_, seen := m.reported[id]
+	unreported := !seen && !m.tasks[id]
+	if unreported {
+		m.reported[id] = false
+	}
+	m.mu.Unlock()
+
+	if unreported {
+		log.Printf("Job %s (%s) does not report when it starts running, so it is never listed as running. "+
+			"Call Monitor.BeforeJobRuns from its BeforeJobRuns listener.", name, id)
+	}
+}
+
+// IncrementJob is part of gocron.Monitor, run counts are derived from the history instead
+func (m *Monitor) IncrementJob(_ uuid.UUID, _ string, _ []string, _ gocron.JobStatus) {}
+
+// RecordJobTiming is part of gocron.Monitor, timings are recorded by RecordJobTimingWithStatus
+func (m *Monitor) RecordJobTiming(_, _ time.Time, _ uuid.UUID, _ string, _ []string) {}
+
+// RecordJobTimingWithStatus records a finished execution of a job
+func (m *Monitor) RecordJobTimingWithStatus(startTime, endTime time.Time, id uuid.UUID, name string, tags []string, status gocron.JobStatus, err error) {
+	run, tracked := m.finishRun(id, startTime)
+	if !tracked {
+		m.checkReported(id, name)
+	}
+	if status != gocron.Success && status != gocron.Fail {
+		if tracked {
+			m.notify()
+		}
+		return
+	}
+
+	rec := runRecord{
+		jobID:     id,
+		jobName:   name,
+		tags:      tags,
+		startedAt: startTime,
+		endedAt:   endTime,
+		err:       err,
+	}
+	if tracked {
+		rec.run = &run
+	}
+
+	m.mu.Lock()
+	s := m.server
+	if s == nil {
+		// the scheduler may already be running jobs before the server is created
+		if len(m.pending) < maxPendingRecords {
+			m.pending = append(m.pending, rec)
+		}
+		m.mu.Unlock()
+		return
+	}
+	m.mu.Unlock()
+
+	s.recordRun(rec)
+	s.notifyJobsChanged()
+}
+
+// finishRun removes the tracked execution which a finished run reported by gocron belongs to.
+// Without singleton mode a job can run several times at once, the execution is the one which started
+// closest to when gocron started timing the run: the listener reports the start right before, the task
+// right after.
+func (m *Monitor) finishRun(id uuid.UUID, startTime time.Time) (ActiveRun, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := m.running[id]
+	if len(runs) == 0 {
+		return ActiveRun{}, false
+	}
+	match, closest := 0, time.Duration(math.MaxInt64)
+	for i, run := range runs {
+		if d := run.StartedAt.Sub(startTime).Abs(); d < closest {
+			match, closest = i, d
+		}
+	}
+
+	run := runs[match]
+	if len(runs) == 1 {
+		delete(m.running, id)
+	} else {
+		m.running[id] = append(runs[:match:match], runs[match+1:]...)
+	}
+	return run, true
+}
+
+// attachCancel makes the latest execution of a job which has no cancel function yet cancellable.
+// It is called by the task as it starts, right after its start was recorded.
+func (m *Monitor) attachCancel(id uuid.UUID, cancel context.CancelFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := m.running[id]
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].cancel == nil {
+			runs[i].cancel = cancel
+			runs[i].Cancellable = true
+			return
+		}
+	}
+}
+
+// cancelRun cancels an execution which is in progress and reports whether it was found and could be cancelled
+func (m *Monitor) cancelRun(id uuid.UUID, runID string) (bool, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := m.running[id]
+	for i := range runs {
+		if runs[i].ID != runID {
+			continue
+		}
+		if runs[i].cancel == nil {
+			return true, false
+		}
+		runs[i].cancelled = true
+		runs[i].cancel()
+		return true, true
+	}
+	return false, false
+}
+
+// markCancelled sets whether the executions of a job which are in progress and can be cancelled are recorded
+// as cancelled once they finish, for when gocron cancels their context because the job is removed
+func (m *Monitor) markCancelled(id uuid.UUID, cancelled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, run := range m.running[id] {
+		if run.cancel != nil {
+			m.running[id][i].cancelled = cancelled
+		}
+	}
+}
+
+// notify tells the attached server, if any, that the running state changed
+func (m *Monitor) notify() {
+	m.mu.Lock()
+	s := m.server
+	m.mu.Unlock()
+	if s != nil {
+		s.notifyJobsChanged()
+	}
+}
+
+// Running lists the executions which are in progress, oldest first
+func (m *Monitor) Running() []ActiveRun {
+	m.mu.Lock()
+	result := make([]ActiveRun, 0)
+	for _, runs := range m.running {
+		result = append(result, runs...)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+// runningOf lists the executions of a job which are in progress, oldest first
+func (m *Monitor) runningOf(id uuid.UUID) []ActiveRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]ActiveRun(nil), m.running[id]...)
+}
+
+// attach starts forwarding runs to the server, flushing anything recorded before it existed
+func (m *Monitor) attach(s *Server) {
+	m.mu.Lock()
+	m.server = s
+	pending := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+
+	for _, rec := range pending {
+		s.recordRun(rec)
+	}
+}
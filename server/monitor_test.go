@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+// newMonitoredServer creates a server on a started scheduler which is connected to a monitor
+func newMonitoredServer(t *testing.T, opts ...Option) (*Server, gocron.Scheduler) {
+	t.Helper()
+	monitor := NewMonitor()
+	scheduler, err := gocron.NewScheduler(monitor.SchedulerOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+
+	s, err := NewServer(scheduler, 0, append([]Option{WithMonitor(monitor)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	return s, scheduler
+}
+
+func runningIDs(m *Monitor) map[string]int {
+	ids := make(map[string]int)
+	for _, run := range m.Running() {
+		ids[run.JobID]++
+	}
+	return ids
+}
+
+func TestMonitorTracksOnlyStartedRuns(t *testing.T) {
+	s, scheduler := newMonitoredServer(t)
+	release := make(chan struct{})
+	defer close(release)
+
+	// gocron calls BeforeJobRuns before this listener skips every run
+	skipped := make(chan struct{}, 100)
+	_, err := s.NewJob(DurationJob(20*time.Millisecond), NewTask(func() {}), gocron.WithName("skipped"),
+		gocron.WithEventListeners(gocron.BeforeJobRunsSkipIfBeforeFuncErrors(func(uuid.UUID, string) error {
+			skipped <- struct{}{}
+			return errors.New("not now")
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{}, 3)
+	block := func() {
+		started <- struct{}{}
+		<-release
+	}
+	managed, err := s.NewJob(DurationJob(time.Hour), NewTask(block), gocron.WithName("managed"),
+		gocron.WithStartAt(gocron.WithStartImmediately()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownID, err := s.NewJob(DurationJob(time.Hour), NewTask(block), gocron.WithName("own id"),
+		gocron.WithIdentifier(uuid.New()), gocron.WithStartAt(gocron.WithStartImmediately()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, err := scheduler.NewJob(gocron.DurationJob(time.Hour), gocron.NewTask(block), gocron.WithName("direct"),
+		gocron.WithStartAt(gocron.WithStartImmediately()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the jobs did not start")
+		}
+	}
+	for range 3 {
+		<-skipped
+	}
+
+	ids := runningIDs(s.monitor)
+	for _, job := range []gocron.Job{managed, ownID, direct} {
+		if ids[job.ID().String()] != 1 {
+			t.Errorf("%s is listed %d times as running, want once", job.Name(), ids[job.ID().String()])
+		}
+	}
+	if len(ids) != 3 {
+		t.Errorf("running jobs = %v, the skipped runs must not be listed", ids)
+	}
+}
+
+func TestMonitorFinishesTaskRuns(t *testing.T) {
+	s, _ := newMonitoredServer(t)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	job, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {
+		close(started)
+		<-release
+	}), gocron.WithName("cleanup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.markManualRun(job.ID())
+	if err := job.RunNow(); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	running := s.monitor.runningOf(job.ID())
+	if len(running) != 1 || running[0].Trigger != TriggerManual || running[0].JobName != "cleanup" {
+		t.Fatalf("running = %+v, want the manual run", running)
+	}
+	close(release)
+
+	var runs []JobRun
+	deadline := time.Now().Add(5 * time.Second)
+	for len(runs) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		runs, _, _ = s.history.List(job.ID().String(), 0, 10)
+	}
+	if left := s.monitor.runningOf(job.ID()); len(left) > 0 {
+		t.Errorf("still running: %+v", left)
+	}
+	if len(runs) != 1 || runs[0].ID != running[0].ID || runs[0].Trigger != TriggerManual {
+		t.Errorf("history = %+v, want the tracked run %s", runs, running[0].ID)
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the body of webhook alerts, as "sha256=" and the hex digest
+const SignatureHeader = "X-Gocron-Signature"
+
+// WebhookNotifier posts alerts as JSON. With a secret the body is signed in the SignatureHeader,
+// so that the receiver can verify it was sent by the server.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	// Headers are added to every request, e.g. for the receiver's authentication
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// NewWebhookNotifier creates a notifier which posts alerts to a URL, the secret may be empty
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: http.DefaultClient}
+}
+
+// Notify posts the alert
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(n.Headers)+1)
+	for key, value := range n.Headers {
+		headers[key] = value
+	}
+	if n.Secret != "" {
+		headers[SignatureHeader] = Signature(n.Secret, body)
+	}
+	return postJSON(ctx, n.Client, n.URL, body, headers)
+}
+
+// Signature computes the value of the SignatureHeader for a body, receivers of webhook alerts compare it
+// with hmac.Equal
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook, or to a chat with a compatible API such as Mattermost
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// NewSlackNotifier creates a notifier which posts alerts to an incoming webhook
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{WebhookURL: webhookURL, Client: http.DefaultClient}
+}
+
+// Notify posts the alert as a message
+func (n *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	text := alertSubject(alert) + "\n" + alert.Message
+	if len(alert.Tags) > 0 {
+		text += "\nTags: " + strings.Join(alert.Tags, ", ")
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.Client, n.WebhookURL, body, nil)
+}
+
+// SMTPConfig configures the delivery of alerts by email
+type SMTPConfig struct {
+	// Addr is the host and port of the mail server, e.g. smtp.example.com:587. STARTTLS is used when the
+	// server offers it.
+	Addr     string
+	From     string
+	To       []string
+	Username string // authenticates with PLAIN if set, which net/smtp only allows over TLS or to localhost
+	Password string
+}
+
+// SMTPNotifier sends alerts by email
+type SMTPNotifier struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+	// sendMail is smtp.SendMail, which does not take a context
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier creates a notifier which sends alerts through a mail server
+func NewSMTPNotifier(cfg SMTPConfig) (*SMTPNotifier, error) {
+	if cfg.Addr == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("gocron-ui: SMTP notifier needs an address, a sender and recipients")
+	}
+	n := &SMTPNotifier{cfg: cfg, sendMail: smtp.SendMail}
+	if cfg.Username != "" {
+		host := cfg.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		n.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return n, nil
+}
+
+// Notify sends the alert as a plain text email
+func (n *SMTPNotifier) Notify(ctx context.Context, alert Alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", alertSubject(alert)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	msg.WriteString(alert.Message + "\r\n\r\n")
+	if alert.JobID != "" {
+		fmt.Fprintf(&msg, "Job: %s (%s)\r\n", alert.JobName, alert.JobID)
+	}
+	if len(alert.Tags) > 0 {
+		fmt.Fprintf(&msg, "Tags: %s\r\n", strings.Join(alert.Tags, ", "))
+	}
+	fmt.Fprintf(&msg, "Rule: %s (%s)\r\n", alert.RuleName, alert.Condition)
+	fmt.Fprintf(&msg, "Time: %s\r\n", alert.Time.Format(time.RFC3339))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- n.sendMail(n.cfg.Addr, n.auth, n.cfg.From, n.cfg.To, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// alertSubject is the one-line summary of an alert
+func alertSubject(alert Alert) string {
+	prefix := "[gocron]"
+	if alert.Test {
+		prefix = "[gocron test]"
+	}
+	name := alert.JobName
+	if name == "" {
+		name = alert.JobID
+	}
+	if name == "" {
+		return fmt.Sprintf("%s %s", prefix, alert.RuleName)
+	}
+	return fmt.Sprintf("%s %s: %s", prefix, name, strings.ReplaceAll(alert.Condition, "_", " "))
+}
+
+// postJSON posts a JSON body and fails unless the receiver answers with a 2xx status
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testAlert() Alert {
+	return Alert{
+		ID:                  "a1",
+		Time:                time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC),
+		RuleID:              "r1",
+		RuleName:            "cleanup keeps failing",
+		Condition:           AlertOnConsecutiveFailures,
+		JobID:               "550e8400-e29b-41d4-a716-446655440000",
+		JobName:             "cleanup",
+		Tags:                []string{"ops", "nightly"},
+		Message:             `Job "cleanup" failed 3 times in a row: disk full`,
+		ConsecutiveFailures: 3,
+	}
+}
+
+// receiver is an httptest server which records the requests of a notifier
+type receiver struct {
+	server *httptest.Server
+	status int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	t.Helper()
+	rcv := &receiver{status: status}
+	rcv.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, r)
+		rcv.bodies = append(rcv.bodies, body)
+		rcv.mu.Unlock()
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(rcv.server.Close)
+	return rcv
+}
+
+// last returns the last request and its body, failing the test if there was none
+func (rcv *receiver) last(t *testing.T) (*http.Request, []byte) {
+	t.Helper()
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.requests) == 0 {
+		t.Fatal("no request received")
+	}
+	return rcv.requests[len(rcv.requests)-1], rcv.bodies[len(rcv.bodies)-1]
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	rcv := newReceiver(t, http.StatusNoContent)
+	n := NewWebhookNotifier(rcv.server.URL, "s3cret")
+	n.Headers = map[string]string{"Authorization": "Bearer token"}
+
+	alert := testAlert()
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	req, body := rcv.last(t)
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s", req.Method)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	// verified the way a receiver would
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.Header.Get(SignatureHeader); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+
+	var got Alert
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != alert.ID || got.JobName != alert.JobName || got.Message != alert.Message ||
+		got.ConsecutiveFailures != 3 || !got.Time.Equal(alert.Time) || strings.Join(got.Tags, ",") != "ops,nightly" {
+		t.Errorf("body = %+v, want %+v", got, alert)
+	}
+}
+
+func TestWebhookNotifierWithoutSecret(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	if err := NewWebhookNotifier(rcv.server.URL, "").Notify(context.Background(), testAlert()); err != nil {
+		t.Fatal(err)
+	}
+	if req, _ := rcv.last(t); req.Header.Get(SignatureHeader) != "" {
+		t.Errorf("unsigned webhook has a %s header", SignatureHeader)
+	}
+}
+
+func TestWebhookNotifierRejected(t *testing.T) {
+	rcv := newReceiver(t, http.StatusUnauthorized)
+	err := NewWebhookNotifier(rcv.server.URL, "s3cret").Notify(context.Background(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want the receiver's status", err)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	if err := NewSlackNotifier(rcv.server.URL).Notify(context.Background(), testAlert()); err != nil {
+		t.Fatal(err)
+	}
+
+	req, body := rcv.last(t)
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := "[gocron] cleanup: consecutive failures\n" +
+		`Job "cleanup" failed 3 times in a row: disk full` + "\n" +
+		"Tags: ops, nightly"
+	if len(payload) != 1 || payload["text"] != want {
+		t.Errorf("payload = %v, want text %q", payload, want)
+	}
+
+	test := testAlert()
+	test.Test = true
+	test.Tags = nil
+	if err := NewSlackNotifier(rcv.server.URL).Notify(context.Background(), test); err != nil {
+		t.Fatal(err)
+	}
+	_, body = rcv.last(t)
+	if !strings.HasPrefix(string(body), `{"text":"[gocron test] cleanup`) || strings.Contains(string(body), "Tags:") {
+		t.Errorf("test alert = %s", body)
+	}
+}
+
+// smtpMessage is a mail received by the stub SMTP server
+type smtpMessage struct {
+	auth string // the decoded PLAIN credentials
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP runs a minimal SMTP server on localhost which accepts every mail, and returns its address and the
+// received mails. It offers PLAIN authentication but no STARTTLS, which net/smtp allows to localhost.
+func serveSMTP(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handleSMTP(conn, messages)
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func handleSMTP(conn net.Conn, messages chan<- smtpMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	var msg smtpMessage
+	reply("220 localhost ESMTP stub")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			if err != nil {
+				reply("501 invalid credentials")
+				continue
+			}
+			msg.auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.data = data.String()
+			messages <- msg
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := serveSMTP(t)
+	n, err := NewSMTPNotifier(SMTPConfig{
+		Addr:     addr,
+		From:     "gocron@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+		Username: "gocron",
+		Password: "hunter2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg smtpMessage
+	select {
+	case msg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+	if msg.auth != "\x00gocron\x00hunter2" {
+		t.Errorf("auth = %q", msg.auth)
+	}
+	if msg.from != "gocron@example.com" || strings.Join(msg.to, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("envelope from %q to %v", msg.from, msg.to)
+	}
+	for _, want := range []string{
+		"From: gocron@example.com\r\n",
+		"To: ops@example.com, oncall@example.com\r\n",
+		"Subject: [gocron] cleanup: consecutive failures\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n",
+		`Job "cleanup" failed 3 times in a row: disk full`,
+		"Job: cleanup (550e8400-e29b-41d4-a716-446655440000)\r\n",
+		"Tags: ops, nightly\r\n",
+		"Rule: cleanup keeps failing (consecutive_failures)\r\n",
+		"Time: 2025-01-15T10:30:00Z\r\n",
+	} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("mail does not contain %q:\n%s", want, msg.data)
+		}
+	}
+}
+
+func TestSMTPNotifierEncodesSubject(t *testing.T) {
+	addr, messages := serveSMTP(t)
+	n, err := NewSMTPNotifier(SMTPConfig{Addr: addr, From: "gocron@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert := testAlert()
+	alert.JobName = "nettoyage à minuit"
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-messages
+	if msg.auth != "" {
+		t.Errorf("authenticated without credentials")
+	}
+	if !strings.Contains(msg.data, "Subject: =?utf-8?q?") {
+		t.Errorf("subject is not encoded:\n%s", msg.data)
+	}
+}
+
+func TestSMTPNotifierTimeout(t *testing.T) {
+	n, err := NewSMTPNotifier(SMTPConfig{Addr: "127.0.0.1:25", From: "gocron@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	defer close(release)
+	n.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := n.Notify(ctx, testAlert()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's error", err)
+	}
+}
+
+func TestNewSMTPNotifierValidates(t *testing.T) {
+	if _, err := NewSMTPNotifier(SMTPConfig{Addr: "localhost:25", From: "gocron@example.com"}); err == nil {
+		t.Error("created a notifier without recipients")
+	}
+}
@@ -0,0 +1,369 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// oidcLoginTTL is how long a user has to log in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcClockSkew is the difference between the clocks of the server and the provider which is tolerated
+const oidcClockSkew = time.Minute
+
+const oidcLoginCookie = "gocron_ui_oidc"
+
+// OIDCConfig configures an OIDCAuthenticator
+type OIDCConfig struct {
+	// IssuerURL is the URL of the provider, its configuration is discovered at /.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	// RedirectURL is where the provider sends users back to, the /auth/oidc/callback route of the server,
+	// e.g. https://scheduler.example.com/auth/oidc/callback
+	RedirectURL string
+	// Scopes are requested in addition to openid, by default profile and email
+	Scopes []string
+	// Label is the text of the login button, by default "Log in with SSO"
+	Label string
+
+	// NameClaim is the claim used as the user's name, by default preferred_username, falling back to email and sub
+	NameClaim string
+	// GroupsClaim is the claim listing the user's groups, by default groups
+	GroupsClaim string
+	// RoleMapping maps groups to roles, groups without a mapping are ignored
+	RoleMapping map[string][]string
+	// DefaultRoles are given to every user who logs in
+	DefaultRoles []string
+	// RoleMapper replaces NameClaim, GroupsClaim and RoleMapping with custom logic if set
+	RoleMapper func(claims map[string]any) (Principal, error)
+
+	// Sessions keeps users logged in after they logged in at the provider, it is required
+	Sessions *SessionAuthenticator
+	// HTTPClient talks to the provider, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// OIDCAuthenticator logs users in at an OpenID Connect provider with the authorization code flow and PKCE.
+// The ID token returned by the provider is verified against the provider's keys, mapped to a Principal and kept
+// in a session, which is what authenticates the following requests.
+type OIDCAuthenticator struct {
+	cfg       OIDCConfig
+	discovery oidcDiscovery
+	keys      *keySet
+}
+
+var (
+	_ RouteAuthenticator = (*OIDCAuthenticator)(nil)
+	_ LoginProvider      = (*OIDCAuthenticator)(nil)
+)
+
+// oidcDiscovery is the part of the provider configuration the authenticator uses
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcLogin is kept in a signed cookie while the user logs in at the provider
+type oidcLogin struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Expires  int64  `json:"e"`
+}
+
+// NewOIDCAuthenticator discovers the configuration of the provider and creates the authenticator
+func NewOIDCAuthenticator(ctx context.Context, cfg OIDCConfig) (*OIDCAuthenticator, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("gocron-ui: OIDC needs an issuer URL, a client ID and a redirect URL")
+	}
+	if cfg.Sessions == nil {
+		return nil, errors.New("gocron-ui: OIDC needs a session authenticator to keep users logged in")
+	}
+	if cfg.Scopes == nil {
+		cfg.Scopes = []string{"profile", "email"}
+	}
+	if cfg.Label == "" {
+		cfg.Label = "Log in with SSO"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	issuer := strings.TrimSuffix(cfg.IssuerURL, "/")
+	var discovery oidcDiscovery
+	if err := getJSON(ctx, cfg.HTTPClient, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("gocron-ui: discovering the OIDC provider: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("gocron-ui: OIDC provider reports the issuer %q instead of %q", discovery.Issuer, cfg.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("gocron-ui: OIDC provider configuration is incomplete")
+	}
+
+	return &OIDCAuthenticator{
+		cfg:       cfg,
+		discovery: discovery,
+		keys:      newKeySet(discovery.JWKSURI, cfg.HTTPClient),
+	}, nil
+}
+
+// Authenticate checks the session the authenticator started after a login
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.cfg.Sessions.Authenticate(r)
+}
+
+// RegisterRoutes adds the routes which start a login and receive the user back from the provider
+func (a *OIDCAuthenticator) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/oidc/login", a.Login).Methods("GET")
+	router.HandleFunc("/oidc/callback", a.Callback).Methods("GET")
+	router.HandleFunc("/logout", a.cfg.Sessions.Logout).Methods("POST")
+}
+
+// LoginMethods offers the login at the provider
+func (a *OIDCAuthenticator) LoginMethods() []LoginMethod {
+	return []LoginMethod{{Type: LoginRedirect, Label: a.cfg.Label, URL: "/auth/oidc/login"}}
+}
+
+// Login sends the user to the provider
+func (a *OIDCAuthenticator) Login(w http.ResponseWriter, r *http.Request) {
+	login := oidcLogin{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: randomString(),
+		Expires:  time.Now().Add(oidcLoginTTL).Unix(),
+	}
+	value, err := a.cfg.Sessions.sign(purposeOIDCLogin, login)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    value,
+		Path:     BasePathFromContext(r.Context()) + "/auth/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   a.cfg.Sessions.cfg.Secure,
+		SameSite: http.SameSiteLaxMode, // sent along when the provider redirects back
+	})
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.cfg.ClientID},
+		"redirect_uri":          {a.cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, a.cfg.Scopes...), " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	http.Redirect(w, r, addQuery(a.discovery.AuthorizationEndpoint, query), http.StatusFound)
+}
+
+// Callback receives the user back from the provider, exchanges the code for an ID token and starts a session
+func (a *OIDCAuthenticator) Callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(oidcLoginCookie)
+	var login oidcLogin
+	if err != nil || !a.cfg.Sessions.verify(purposeOIDCLogin, cookie.Value, &login) || time.Now().Unix() >= login.Expires {
+		respondError(w, http.StatusBadRequest, "Login expired, please log in again")
+		return
+	}
+	// the login can only be completed once
+	http.SetCookie(w, &http.Cookie{Name: oidcLoginCookie, Path: BasePathFromContext(r.Context()) + "/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	query := r.URL.Query()
+	if query.Get("state") != login.State {
+		respondError(w, http.StatusBadRequest, "Invalid login state")
+		return
+	}
+	if errCode := query.Get("error"); errCode != "" {
+		respondError(w, http.StatusUnauthorized, fmt.Sprintf("Login failed: %s %s", errCode, query.Get("error_description")))
+		return
+	}
+
+	p, err := a.exchange(r.Context(), query.Get("code"), login)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		respondError(w, http.StatusUnauthorized, "Login failed")
+		return
+	}
+	if err := a.cfg.Sessions.StartSession(w, r, *p); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.Redirect(w, r, BasePathFromContext(r.Context())+"/", http.StatusFound)
+}
+
+// exchange redeems an authorization code for an ID token and maps its claims to the caller
+func (a *OIDCAuthenticator) exchange(ctx context.Context, code string, login oidcLogin) (*Principal, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.cfg.RedirectURL},
+		"code_verifier": {login.Verifier},
+	}
+	if a.cfg.ClientSecret == "" {
+		// public clients identify themselves in the form, confidential ones with their secret
+		form.Set("client_id", a.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+	}
+
+	resp, err := a.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("redeeming the code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("redeeming the code: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("redeeming the code: %s %s", resp.Status, tokens.Error)
+	}
+
+	claims, err := a.verifyIDToken(ctx, tokens.IDToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return a.principal(claims)
+}
+
+// verifyIDToken checks the signature and the claims of an ID token and returns its claims
+func (a *OIDCAuthenticator) verifyIDToken(ctx context.Context, token, nonce string) (map[string]any, error) {
+	payload, err := a.keys.verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("ID token: %w", err)
+	}
+
+	var claims map[string]any
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("ID token: %w", err)
+	}
+
+	now := time.Now()
+	if iss, _ := claims["iss"].(string); iss != a.discovery.Issuer {
+		return nil, fmt.Errorf("ID token: issued by %q instead of %q", iss, a.discovery.Issuer)
+	}
+	if !slices.Contains(stringsClaim(claims["aud"]), a.cfg.ClientID) {
+		return nil, errors.New("ID token: not issued for this client")
+	}
+	exp, ok := timeClaim(claims["exp"])
+	if !ok || now.After(exp.Add(oidcClockSkew)) {
+		return nil, errors.New("ID token: expired")
+	}
+	if iat, ok := timeClaim(claims["iat"]); ok && iat.After(now.Add(oidcClockSkew)) {
+		return nil, errors.New("ID token: issued in the future")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("ID token: nonce does not match the login")
+	}
+	return claims, nil
+}
+
+// principal maps the claims of an ID token to the caller
+func (a *OIDCAuthenticator) principal(claims map[string]any) (*Principal, error) {
+	if a.cfg.RoleMapper != nil {
+		p, err := a.cfg.RoleMapper(claims)
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+
+	p := &Principal{Roles: slices.Clone(a.cfg.DefaultRoles)}
+	for _, claim := range []string{a.cfg.NameClaim, "preferred_username", "email", "sub"} {
+		if name, _ := claims[claim].(string); claim != "" && name != "" {
+			p.Name = name
+			break
+		}
+	}
+	for _, group := range stringsClaim(claims[a.cfg.GroupsClaim]) {
+		for _, role := range a.cfg.RoleMapping[group] {
+			if !slices.Contains(p.Roles, role) {
+				p.Roles = append(p.Roles, role)
+			}
+		}
+	}
+	return p, nil
+}
+
+// stringsClaim reads a claim which is a string or a list of strings, like aud and groups
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// timeClaim reads a claim holding seconds since the epoch
+func timeClaim(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// randomString returns 256 random bits, encoded to be used in URLs
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// addQuery adds parameters to a URL which may have a query already
+func addQuery(rawURL string, query url.Values) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + query.Encode()
+	}
+	return rawURL + "?" + query.Encode()
+}
@@ -0,0 +1,442 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testClientID = "gocron-ui"
+
+// testKey is a signing key of the fake provider
+type testKey struct {
+	kid    string
+	signer crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, signer: key}
+}
+
+func newECKey(t *testing.T, kid string, curve elliptic.Curve) testKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, signer: key}
+}
+
+// jwk describes the public key as the provider publishes it
+func (k testKey) jwk() jwk {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	switch pub := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: k.kid, Use: "sig", N: encode(pub.N.Bytes()), E: encode(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return jwk{
+			Kty: "EC", Kid: k.kid, Use: "sig", Crv: pub.Curve.Params().Name,
+			X: encode(pub.X.FillBytes(make([]byte, size))), Y: encode(pub.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	panic("unsupported key")
+}
+
+// signToken creates a compact JWS of the claims, the algorithm is put in the header as given even if it does not
+// fit the key, so that tests can lie about it
+func signToken(t *testing.T, key testKey, alg string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": key.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := crypto.SHA256
+	switch alg[len(alg)-3:] {
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	var err error
+	switch priv := key.signer.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			sig, err = rsa.SignPSS(rand.Reader, priv, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, priv, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, priv, digest)
+		size := (priv.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// fakeProvider is an OpenID Connect provider which issues ID tokens for any user who is sent to it
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mutex       sync.Mutex
+	keys        []testKey // published
+	signingKey  testKey
+	logins      map[string]url.Values // authorization requests by code
+	jwksFetches int
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{t: t, logins: make(map[string]url.Values)}
+	p.signingKey = newRSAKey(t, "rsa-1")
+	p.keys = []testKey{p.signingKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, oidcDiscovery{
+			Issuer:                p.server.URL,
+			AuthorizationEndpoint: p.server.URL + "/authorize",
+			TokenEndpoint:         p.server.URL + "/token",
+			JWKSURI:               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.jwksFetches++
+		keys := make([]jwk, 0, len(p.keys))
+		for _, key := range p.keys {
+			keys = append(keys, key.jwk())
+		}
+		respondJSON(w, http.StatusOK, map[string]any{"keys": keys})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize logs the user in as the provider's login page would and returns the code it redirects back with
+func (p *fakeProvider) authorize(query url.Values) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	code := randomString()
+	p.logins[code] = query
+	return code
+}
+
+// token redeems a code once, if the PKCE verifier matches the challenge of the login
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mutex.Lock()
+	login, ok := p.logins[r.Form.Get("code")]
+	delete(p.logins, r.Form.Get("code"))
+	key := p.signingKey
+	p.mutex.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || login.Get("code_challenge_method") != "S256" ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != login.Get("code_challenge") ||
+		r.Form.Get("redirect_uri") != login.Get("redirect_uri") {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := p.validClaims(login.Get("nonce"))
+	respondJSON(w, http.StatusOK, map[string]string{"id_token": signToken(p.t, key, "RS256", claims)})
+}
+
+func (p *fakeProvider) validClaims(nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":                p.server.URL,
+		"aud":                testClientID,
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"groups":             []string{"ops"},
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              nonce,
+	}
+}
+
+func (p *fakeProvider) publish(keys ...testKey) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.keys = keys
+}
+
+func (p *fakeProvider) fetches() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.jwksFetches
+}
+
+func newTestOIDC(t *testing.T, p *fakeProvider) *OIDCAuthenticator {
+	t.Helper()
+	sessions, err := NewSessionAuthenticator(SessionConfig{Secret: []byte(strings.Repeat("s", 32))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{
+		IssuerURL:   p.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "https://scheduler.example.com/auth/oidc/callback",
+		RoleMapping: map[string][]string{"ops": {RoleOperator}},
+		Sessions:    sessions,
+		HTTPClient:  p.server.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := newFakeProvider(t)
+	a := newTestOIDC(t, p)
+
+	rec := httptest.NewRecorder()
+	a.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login answered %d", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Host != strings.TrimPrefix(p.server.URL, "http://") || location.Path != "/authorize" {
+		t.Fatalf("login redirected to %s", location)
+	}
+	query := location.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" || query.Get("nonce") == "" {
+		t.Fatalf("login did not use PKCE and a nonce: %s", query.Encode())
+	}
+	loginCookie := responseCookies(rec)[0]
+
+	callback := func(state, code string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), nil)
+		r.AddCookie(loginCookie)
+		rec := httptest.NewRecorder()
+		a.Callback(rec, r)
+		return rec
+	}
+
+	if rec := callback("forged", p.authorize(query)); rec.Code != http.StatusBadRequest {
+		t.Errorf("callback with a forged state answered %d", rec.Code)
+	}
+
+	rec = callback(query.Get("state"), p.authorize(query))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback answered %d: %s", rec.Code, rec.Body)
+	}
+	var session *http.Cookie
+	for _, cookie := range responseCookies(rec) {
+		if cookie.Name == "gocron_ui_session" {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatal("callback did not start a session")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/jobs", nil)
+	r.AddCookie(session)
+	principal, err := a.Authenticate(r)
+	if err != nil || principal == nil {
+		t.Fatalf("session did not authenticate: %v", err)
+	}
+	if principal.Name != "alice" || len(principal.Roles) != 1 || principal.Roles[0] != RoleOperator {
+		t.Errorf("got principal %+v", principal)
+	}
+}
+
+func TestOIDCTokenExchangeRequiresVerifier(t *testing.T) {
+	p := newFakeProvider(t)
+	a := newTestOIDC(t, p)
+
+	challenge := sha256.Sum256([]byte("the verifier"))
+	code := p.authorize(url.Values{
+		"redirect_uri":          {a.cfg.RedirectURL},
+		"nonce":                 {"n"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	})
+	if _, err := a.exchange(context.Background(), code, oidcLogin{Nonce: "n", Verifier: "another verifier"}); err == nil {
+		t.Fatal("code was redeemed with the wrong verifier")
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	p := newFakeProvider(t)
+	rsaKey := p.signingKey
+	ecKey := newECKey(t, "ec-256", elliptic.P256())
+	ec384Key := newECKey(t, "ec-384", elliptic.P384())
+	p.publish(rsaKey, ecKey, ec384Key)
+	a := newTestOIDC(t, p)
+
+	const nonce = "the nonce"
+	claims := func(change func(map[string]any)) map[string]any {
+		c := p.validClaims(nonce)
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	unknownKey := newRSAKey(t, rsaKey.kid) // same ID, but not the published key
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", signToken(t, rsaKey, "RS256", claims(nil)), true},
+		{"RS512", signToken(t, rsaKey, "RS512", claims(nil)), true},
+		{"PS256", signToken(t, rsaKey, "PS256", claims(nil)), true},
+		{"ES256", signToken(t, ecKey, "ES256", claims(nil)), true},
+		{"ES384", signToken(t, ec384Key, "ES384", claims(nil)), true},
+		{"audience list", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			c["aud"] = []string{"other", testClientID}
+		})), true},
+
+		{"signed by another key", signToken(t, unknownKey, "RS256", claims(nil)), false},
+		{"tampered payload", tamper(signToken(t, rsaKey, "RS256", claims(nil)), claims(func(c map[string]any) {
+			c["preferred_username"] = "mallory"
+		})), false},
+		{"alg none", unsigned("none", rsaKey.kid, claims(nil)), false},
+		{"alg HS256", unsigned("HS256", rsaKey.kid, claims(nil)), false},
+		{"RSA algorithm with an EC key", signToken(t, ecKey, "RS256", claims(nil)), false},
+		{"EC algorithm with an RSA key", signToken(t, rsaKey, "ES256", claims(nil)), false},
+		{"PKCS1 signature as PS256", relabel(signToken(t, rsaKey, "RS256", claims(nil)), "PS256", rsaKey.kid), false},
+		{"wrong issuer", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			c["iss"] = "https://evil.example.com"
+		})), false},
+		{"wrong audience", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			c["aud"] = "another-client"
+		})), false},
+		{"expired", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			c["exp"] = time.Now().Add(-oidcClockSkew - time.Minute).Unix()
+		})), false},
+		{"without expiry", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			delete(c, "exp")
+		})), false},
+		{"issued in the future", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			c["iat"] = time.Now().Add(oidcClockSkew + time.Hour).Unix()
+		})), false},
+		{"wrong nonce", signToken(t, rsaKey, "RS256", claims(func(c map[string]any) {
+			c["nonce"] = "another login"
+		})), false},
+		{"malformed", "not-a-token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.verifyIDToken(context.Background(), tt.token, nonce)
+			if tt.valid && err != nil {
+				t.Errorf("valid token was rejected: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("invalid token was accepted")
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	p := newFakeProvider(t)
+	a := newTestOIDC(t, p)
+	const nonce = "n"
+
+	oldKey := p.signingKey
+	if _, err := a.verifyIDToken(context.Background(), signToken(t, oldKey, "RS256", p.validClaims(nonce)), nonce); err != nil {
+		t.Fatalf("token of the first key was rejected: %v", err)
+	}
+	if p.fetches() != 1 {
+		t.Fatalf("keys were fetched %d times", p.fetches())
+	}
+
+	newKey := newECKey(t, "ec-2", elliptic.P256())
+	p.publish(newKey)
+	token := signToken(t, newKey, "ES256", p.validClaims(nonce))
+
+	// unknown key IDs do not make the server fetch the keys more than once per interval
+	if _, err := a.verifyIDToken(context.Background(), token, nonce); err == nil {
+		t.Fatal("token of an unknown key was accepted before the keys were fetched again")
+	}
+	if p.fetches() != 1 {
+		t.Fatalf("keys were fetched again within the refresh interval")
+	}
+
+	a.keys.mutex.Lock()
+	a.keys.fetchedAt = a.keys.fetchedAt.Add(-jwksRefreshInterval)
+	a.keys.mutex.Unlock()
+
+	if _, err := a.verifyIDToken(context.Background(), token, nonce); err != nil {
+		t.Fatalf("token of the rotated key was rejected: %v", err)
+	}
+	if p.fetches() != 2 {
+		t.Fatalf("keys were fetched %d times", p.fetches())
+	}
+	// the old key is gone with the rotation
+	if _, err := a.verifyIDToken(context.Background(), signToken(t, oldKey, "RS256", p.validClaims(nonce)), nonce); err == nil {
+		t.Error("token of the retired key was accepted")
+	}
+}
+
+func responseCookies(rec *httptest.ResponseRecorder) []*http.Cookie {
+	res := rec.Result()
+	defer res.Body.Close()
+	return res.Cookies()
+}
+
+// tamper replaces the payload of a token, keeping its signature
+func tamper(token string, claims map[string]any) string {
+	parts := strings.Split(token, ".")
+	payload, _ := json.Marshal(claims)
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+}
+
+// relabel replaces the algorithm in the header of a token, keeping its signature
+func relabel(token, alg, kid string) string {
+	parts := strings.Split(token, ".")
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	return base64.RawURLEncoding.EncodeToString(header) + "." + parts[1] + "." + parts[2]
+}
+
+// unsigned creates a token with an empty signature
+func unsigned(alg, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+// singleton modes of JobOptions
+const (
+	SingletonModeWait       = "wait"
+	SingletonModeReschedule = "reschedule"
+)
+
+// gocronOptions validates the options and builds the gocron job options they stand for.
+// A start time which passed is left out because the job has started already, which is the case
+// when a job is restored or updated.
+func (o *JobOptions) gocronOptions(now time.Time) ([]gocron.JobOption, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	errs := fieldErrors{}
+	var options []gocron.JobOption
+
+	switch o.SingletonMode {
+	case "":
+	case SingletonModeWait:
+		options = append(options, gocron.WithSingletonMode(gocron.LimitModeWait))
+	case SingletonModeReschedule:
+		options = append(options, gocron.WithSingletonMode(gocron.LimitModeReschedule))
+	default:
+		errs["options.singletonMode"] = "Invalid singleton mode. Supported: wait, reschedule"
+	}
+
+	if o.LimitedRuns > 0 {
+		options = append(options, gocron.WithLimitedRuns(o.LimitedRuns))
+	}
+
+	var startAt time.Time
+	if o.StartAt != "" {
+		t, err := time.Parse(time.RFC3339, o.StartAt)
+		switch {
+		case err != nil:
+			errs["options.startAt"] = fmt.Sprintf("Invalid start time %q. Use RFC3339", o.StartAt)
+		case o.StartImmediately:
+			errs["options.startAt"] = "A start time cannot be combined with startImmediately"
+		default:
+			startAt = t
+		}
+	}
+	if o.StartImmediately {
+		options = append(options, gocron.WithStartAt(gocron.WithStartImmediately()))
+	} else if startAt.After(now) {
+		options = append(options, gocron.WithStartAt(gocron.WithStartDateTime(startAt)))
+	}
+
+	if o.StopAt != "" {
+		t, err := time.Parse(time.RFC3339, o.StopAt)
+		switch {
+		case err != nil:
+			errs["options.stopAt"] = fmt.Sprintf("Invalid stop time %q. Use RFC3339", o.StopAt)
+		case !t.After(now):
+			errs["options.stopAt"] = "Stop time must be in the future"
+		case !startAt.IsZero() && !t.After(startAt):
+			errs["options.stopAt"] = "Stop time must be after the start time"
+		default:
+			options = append(options, gocron.WithStopAt(gocron.WithStopDateTime(t)))
+		}
+	}
+
+	if o.Identifier != "" {
+		id, err := uuid.Parse(o.Identifier)
+		if err != nil {
+			errs["options.identifier"] = "Identifier must be a UUID"
+		} else {
+			options = append(options, gocron.WithIdentifier(id))
+		}
+	}
+
+	if err := errs.errOrNil(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// identifier returns the ID the options ask for, or uuid.Nil
+func (o *JobOptions) identifier() uuid.UUID {
+	if o == nil {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(o.Identifier)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// stopped reports whether the stop time of the options has passed
+func (o *JobOptions) stopped(now time.Time) bool {
+	if o == nil || o.StopAt == "" {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, o.StopAt)
+	return err == nil && !t.After(now)
+}
+
+// remaining returns the options to register a job with again, after a restart for example: the job does not start
+// right away again and only has the limited runs left which it did not use yet. It returns false if no runs are left.
+func (o *JobOptions) remaining(runs uint) (*JobOptions, bool) {
+	if o == nil {
+		return nil, true
+	}
+	r := o.copy()
+	r.StartImmediately = false
+	if r.LimitedRuns > 0 {
+		if runs >= r.LimitedRuns {
+			return nil, false
+		}
+		r.LimitedRuns -= runs
+	}
+	return r, true
+}
+
+// copy returns a copy of the options which can be changed independently
+func (o *JobOptions) copy() *JobOptions {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	return &c
+}
+
+// codeJobOptions returns the options to register a job with again which was registered in code, the way remaining
+// does for the options of a job created through the API: options which start the job right away or at a time which
+// passed are left out and a limit of runs only gets the runs left. It returns errNoRunsLeft if no runs are left or
+// the stop time passed.
+func codeJobOptions(options []gocron.JobOption, runs uint, now time.Time) ([]gocron.JobOption, error) {
+	result := make([]gocron.JobOption, 0, len(options))
+	for _, option := range options {
+		effect, err := inspectOption(option, now)
+		switch {
+		case effect.startImmediately, errors.Is(err, gocron.ErrWithStartDateTimePast):
+			continue
+		case errors.Is(err, gocron.ErrWithStopDateTimePast):
+			return nil, errNoRunsLeft
+		case effect.limitedRuns > 0:
+			if runs >= effect.limitedRuns {
+				return nil, errNoRunsLeft
+			}
+			option = gocron.WithLimitedRuns(effect.limitedRuns - runs)
+		}
+		result = append(result, option)
+	}
+	return result, nil
+}
+
+// optionEffect is what a gocron job option does to the start and the runs of a job
+type optionEffect struct {
+	startImmediately bool
+	limitedRuns      uint
+}
+
+// inspectOption applies a gocron job option to an empty job and returns what it set and the option's error.
+// gocron options cannot be inspected otherwise, the fields are read from gocron's internal job. An option
+// whose effect cannot be read this way is reported to have none.
+func inspectOption(option gocron.JobOption, now time.Time) (effect optionEffect, err error) {
+	defer func() {
+		// options which expect a job set up by the scheduler
+		if recover() != nil {
+			effect, err = optionEffect{}, nil
+		}
+	}()
+
+	fn := reflect.ValueOf(option)
+	if fn.IsNil() {
+		return effect, nil
+	}
+	job := reflect.New(fn.Type().In(0).Elem())
+	if out := fn.Call([]reflect.Value{job, reflect.ValueOf(now)}); !out[0].IsNil() {
+		err, _ = out[0].Interface().(error)
+	}
+
+	fields := job.Elem()
+	if f := fields.FieldByName("startImmediately"); f.Kind() == reflect.Bool {
+		effect.startImmediately = f.Bool()
+	}
+	if f := fields.FieldByName("limitRunsTo"); f.Kind() == reflect.Pointer && !f.IsNil() {
+		if limit := f.Elem().FieldByName("limit"); limit.Kind() == reflect.Uint {
+			effect.limitedRuns = uint(limit.Uint())
+		}
+	}
+	return effect, err
+}
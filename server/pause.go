@@ -0,0 +1,298 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// restoredPauseTimeout is how long jobs which were paused before a restart have to be registered again
+// after NewServer to stay paused
+const restoredPauseTimeout = time.Minute
+
+// PauseJob removes a job from the scheduler while keeping its definition, so that it stays listed and can be resumed
+func (s *Server) PauseJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	// the body is optional
+	var req PauseJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	unlock := s.lockJob(id)
+	defer unlock()
+
+	job, ok := s.findJob(id)
+	if !ok {
+		if mj, ok := s.managedJob(id); ok && mj.paused != nil {
+			respondError(w, http.StatusConflict, "Job is already paused")
+			return
+		}
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	if _, ok := s.managedJob(id); !ok {
+		respondError(w, http.StatusConflict, "Job was registered directly on the scheduler and cannot be paused")
+		return
+	}
+
+	info := PauseInfo{
+		Reason:   req.Reason,
+		PausedBy: req.PausedBy,
+		PausedAt: time.Now(),
+	}
+	// an authenticated caller cannot pause in someone else's name
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		info.PausedBy = p.Name
+	}
+	if err := s.pauseJob(job, info); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.persistPause(id, info)
+
+	jobData, _ := s.jobData(id)
+	respondJSON(w, http.StatusOK, jobData)
+}
+
+// ResumeJob registers a paused job again with the same definition and ID
+func (s *Server) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	unlock := s.lockJob(id)
+	defer unlock()
+
+	mj, ok := s.managedJob(id)
+	if !ok || mj.paused == nil {
+		if _, found := s.findJob(id); found {
+			respondError(w, http.StatusConflict, "Job is not paused")
+			return
+		}
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	if err := s.resumeJob(id, mj); err != nil {
+		respondJobError(w, err)
+		return
+	}
+	s.forgetPause(id)
+
+	jobData, _ := s.jobData(id)
+	respondJSON(w, http.StatusOK, jobData)
+}
+
+// pauseJob removes a managed job from the scheduler and marks its definition as paused.
+// gocron cancels the context of the job's runs in progress, which are recorded as cancelled like runs
+// cancelled through CancelRun. Tasks which do not take a context.Context run to completion.
+func (s *Server) pauseJob(job gocron.Job, info PauseInfo) error {
+	lastRun, _ := job.LastRun()
+
+	// the job is marked as paused first so that it is never mistaken for a job which gocron removed
+	s.jobsMutex.Lock()
+	mj, ok := s.jobs[job.ID()]
+	if ok {
+		paused := *mj
+		paused.paused = &info
+		paused.lastRun = lastRun
+		s.jobs[job.ID()] = &paused
+	}
+	s.jobsMutex.Unlock()
+
+	// removing the job cancels the context of its runs in progress
+	if s.monitor != nil {
+		s.monitor.markCancelled(job.ID(), true)
+	}
+	if err := s.Scheduler.RemoveJob(job.ID()); err != nil {
+		if s.monitor != nil {
+			s.monitor.markCancelled(job.ID(), false)
+		}
+		if ok {
+			s.jobsMutex.Lock()
+			s.jobs[job.ID()] = mj
+			s.jobsMutex.Unlock()
+		}
+		return err
+	}
+
+	s.notifyJobsChanged()
+	return nil
+}
+
+// resumeJob adds a paused job to the scheduler again. It does not start right away again and only gets the
+// limited runs it has left, a one-time job only its times which did not pass yet. A job without runs left is
+// not resumed. A job registered in code gets its options again as codeJobOptions rewrites them.
+func (s *Server) resumeJob(id uuid.UUID, mj *managedJob) error {
+	now := time.Now()
+	resumed := *mj
+	resumed.paused = nil
+	resumed.lastRun = time.Time{}
+
+	if resumed.spec.Type == ScheduleOneTime {
+		if len(resumed.spec.Times) == 0 {
+			// the job runs once right away
+			if s.runCount(id) > 0 {
+				return errNoRunsLeft
+			}
+		} else if resumed.spec.Times = futureTimes(resumed.spec.Times, now); len(resumed.spec.Times) == 0 {
+			return errNoRunsLeft
+		}
+	}
+	if mj.request != nil {
+		remaining, ok := mj.request.Options.remaining(s.runCount(id))
+		if !ok || remaining.stopped(now) {
+			return errNoRunsLeft
+		}
+		options, err := remaining.gocronOptions(now)
+		if err != nil {
+			return err
+		}
+		resumed.options = options
+	}
+
+	jobDef, err := resumed.spec.definition()
+	if err != nil {
+		return err
+	}
+
+	options := resumed.jobOptions()
+	if mj.request == nil {
+		// the job keeps the options it was given, its runs are counted since it was registered with them
+		if options, err = codeJobOptions(options, s.runCount(id), now); err != nil {
+			return err
+		}
+	}
+	options = append(options, gocron.WithIdentifier(id))
+	if _, err := s.Scheduler.NewJob(jobDef, s.gocronTask(resumed.task, newJobRef(id)), options...); err != nil {
+		return schedulerError(err)
+	}
+
+	s.jobsMutex.Lock()
+	s.jobs[id] = &resumed
+	s.jobsMutex.Unlock()
+
+	s.notifyJobsChanged()
+	return nil
+}
+
+// restorePause pauses a job which was paused before a restart as soon as it is registered again
+func (s *Server) restorePause(job gocron.Job) {
+	s.jobsMutex.Lock()
+	info, ok := s.restoredPauses[job.ID()]
+	delete(s.restoredPauses, job.ID())
+	s.jobsMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := s.pauseJob(job, info); err != nil {
+		log.Printf("Error pausing restored job %s: %v", job.ID(), err)
+	}
+}
+
+// expireRestoredPauses waits for the jobs which were paused before a restart to be registered again,
+// then forgets the pauses of the jobs which were not
+func (s *Server) expireRestoredPauses() {
+	timer := time.NewTimer(restoredPauseTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		s.dropRestoredPauses()
+	case <-s.done:
+	}
+}
+
+// dropRestoredPauses removes the pauses of jobs which were not registered again since the restart from the store.
+// Jobs created through the API which could not be restored keep theirs, they come back paused once they can be.
+func (s *Server) dropRestoredPauses() {
+	s.jobsMutex.Lock()
+	pauses := s.restoredPauses
+	s.restoredPauses = nil
+	s.jobsMutex.Unlock()
+	if len(pauses) == 0 {
+		return
+	}
+
+	persisted, err := s.store.List(bucketJobs)
+	if err != nil {
+		log.Printf("Error loading jobs from store: %v", err)
+		return
+	}
+	for id := range pauses {
+		if _, ok := persisted[id.String()]; !ok {
+			s.forgetPause(id)
+		}
+	}
+}
+
+// pausedJobsData describes the paused jobs sorted by name
+func (s *Server) pausedJobsData() []JobData {
+	paused := make(map[uuid.UUID]*managedJob)
+	s.jobsMutex.RLock()
+	for id, mj := range s.jobs {
+		if mj.paused != nil {
+			paused[id] = mj
+		}
+	}
+	s.jobsMutex.RUnlock()
+
+	result := make([]JobData, 0, len(paused))
+	for id, mj := range paused {
+		jobData := convertPausedJob(id, mj)
+		// a job can be paused while it runs
+		s.setRunning(&jobData, id)
+		jobData.ConsecutiveFailures = s.consecutiveFailures(jobData.ID)
+		result = append(result, jobData)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// convertPausedJob describes a paused job, which has no upcoming runs while it is not in the scheduler
+func convertPausedJob(id uuid.UUID, mj *managedJob) JobData {
+	spec := mj.spec
+	jobData := JobData{
+		ID:           id.String(),
+		Name:         mj.name,
+		Tags:         mj.tags,
+		LastRun:      formatTime(mj.lastRun),
+		NextRuns:     []string{},
+		ScheduleSpec: &spec,
+		Paused:       true,
+		PauseReason:  mj.paused.Reason,
+		PausedBy:     mj.paused.PausedBy,
+		PausedAt:     formatTime(mj.paused.PausedAt),
+	}
+	if mj.request != nil {
+		jobData.Options = mj.request.Options.copy()
+	}
+	jobData.Schedule, jobData.ScheduleDetail = spec.describe()
+	return jobData
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+func TestCodeJobOptions(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		options []gocron.JobOption
+		runs    uint
+		want    int  // options left
+		limit   uint // runs left, 0 for no limit
+		wantErr error
+	}{
+		{name: "start immediately", options: []gocron.JobOption{gocron.WithName("a"), gocron.WithStartAt(gocron.WithStartImmediately())}, want: 1},
+		{name: "start passed", options: []gocron.JobOption{gocron.WithStartAt(gocron.WithStartDateTime(now.Add(-time.Hour)))}, want: 0},
+		{name: "start ahead", options: []gocron.JobOption{gocron.WithStartAt(gocron.WithStartDateTime(now.Add(time.Hour)))}, want: 1},
+		{name: "runs left", options: []gocron.JobOption{gocron.WithLimitedRuns(3), gocron.WithTags("ops")}, runs: 1, want: 2, limit: 2},
+		{name: "no runs left", options: []gocron.JobOption{gocron.WithLimitedRuns(3)}, runs: 3, wantErr: errNoRunsLeft},
+		{name: "stop passed", options: []gocron.JobOption{gocron.WithStopAt(gocron.WithStopDateTime(now.Add(-time.Minute)))}, wantErr: errNoRunsLeft},
+		{name: "stop ahead", options: []gocron.JobOption{gocron.WithStopAt(gocron.WithStopDateTime(now.Add(time.Hour)))}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := codeJobOptions(tt.options, tt.runs, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(options) != tt.want {
+				t.Fatalf("%d options left, want %d", len(options), tt.want)
+			}
+			var limit uint
+			for _, option := range options {
+				effect, err := inspectOption(option, now)
+				if err != nil || effect.startImmediately {
+					t.Errorf("option left which starts the job: %+v, %v", effect, err)
+				}
+				if effect.limitedRuns > 0 {
+					limit = effect.limitedRuns
+				}
+			}
+			if limit != tt.limit {
+				t.Errorf("limit = %d, want %d", limit, tt.limit)
+			}
+		})
+	}
+}
+
+func TestPauseAndResumeCodeJob(t *testing.T) {
+	s, _ := newMonitoredServer(t)
+
+	started := make(chan struct{}, 10)
+	job, err := s.NewJob(DurationJob(time.Hour), NewTask(func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}), gocron.WithName("sync"), gocron.WithStartAt(gocron.WithStartImmediately()), gocron.WithLimitedRuns(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := job.ID()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not start")
+	}
+
+	post := func(action string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs/"+id.String()+"/"+action, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", action, rec.Code, rec.Body.String())
+		}
+	}
+
+	// pausing cancels the run in progress
+	post("pause")
+	var runs []JobRun
+	deadline := time.Now().Add(5 * time.Second)
+	for len(runs) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		runs, _, _ = s.history.List(id.String(), 0, 10)
+	}
+	if len(runs) != 1 || runs[0].Status != RunStatusCancelled {
+		t.Fatalf("history = %+v, want the cancelled run", runs)
+	}
+
+	// resuming neither starts the job right away again nor gives back the run it used
+	post("resume")
+	select {
+	case <-started:
+		t.Fatal("the resumed job started right away")
+	case <-time.After(200 * time.Millisecond):
+	}
+	resumed, ok := s.findJob(id)
+	if !ok {
+		t.Fatal("the resumed job is not scheduled")
+	}
+	if err := resumed.RunNow(); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// the job had one of its two runs left, gocron removes it once it started
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := s.findJob(id); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the resumed job got its used run back")
+}
+
+func TestDropRestoredPauses(t *testing.T) {
+	store, err := NewFileStore(t.TempDir() + "/store.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	registered, gone := uuid.New(), uuid.New()
+	s := &Server{store: store}
+	s.persistPause(registered, PauseInfo{Reason: "maintenance"})
+	s.persistPause(gone, PauseInfo{Reason: "maintenance"})
+
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+	s, err = NewServer(scheduler, 0, WithStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	if _, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithIdentifier(registered)); err != nil {
+		t.Fatal(err)
+	}
+	if mj, ok := s.managedJob(registered); !ok || mj.paused == nil {
+		t.Fatal("the registered job was not paused again")
+	}
+
+	s.dropRestoredPauses()
+	pauses, err := store.List(bucketPaused)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pauses[gone.String()]; ok || len(pauses) != 1 {
+		t.Errorf("pauses = %v, want only the pause of %s", pauses, registered)
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Permission allows a kind of operation on jobs or the scheduler
+type Permission string
+
+// permissions enforced by the server
+const (
+	PermissionView      Permission = "view"      // see jobs, their runs and the registered tasks
+	PermissionRun       Permission = "run"       // run jobs and cancel their executions
+	PermissionPause     Permission = "pause"     // pause and resume jobs
+	PermissionEdit      Permission = "edit"      // create and update jobs
+	PermissionDelete    Permission = "delete"    // remove jobs
+	PermissionScheduler Permission = "scheduler" // start and stop the scheduler
+	PermissionAudit     Permission = "audit"     // read the audit log
+	PermissionMetrics   Permission = "metrics"   // scrape the metrics of all jobs
+	PermissionAlerts    Permission = "alerts"    // manage the alert rules
+)
+
+// built-in roles
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Role is a named set of permissions, optionally scoped to jobs with certain tags
+type Role struct {
+	Name        string
+	Permissions []Permission
+	// Tags limits the role to jobs which have at least one of the tags, empty means all jobs.
+	// A scoped role never grants globalPermissions, which are about all jobs.
+	Tags []string
+}
+
+// builtinRoles are always defined, WithAuthorization can replace them with roles of the same name
+var builtinRoles = []Role{
+	{Name: RoleViewer, Permissions: []Permission{PermissionView, PermissionMetrics}},
+	{Name: RoleOperator, Permissions: []Permission{PermissionView, PermissionMetrics, PermissionRun, PermissionPause}},
+	{Name: RoleAdmin, Permissions: []Permission{
+		PermissionView, PermissionMetrics, PermissionRun, PermissionPause, PermissionEdit, PermissionDelete,
+		PermissionScheduler, PermissionAudit, PermissionAlerts,
+	}},
+}
+
+// globalPermissions are about all jobs at once, only roles which are not scoped grant them
+var globalPermissions = []Permission{PermissionScheduler, PermissionAudit, PermissionMetrics, PermissionAlerts}
+
+// jobPermissions are the permissions which apply to a single job, in the order the UI lists them
+var jobPermissions = []Permission{PermissionView, PermissionRun, PermissionPause, PermissionEdit, PermissionDelete}
+
+// WithAuthorization enforces role-based permissions on every route. The built-in roles viewer, operator and admin
+// are always defined, roles given here are added to them or replace them. Callers get the roles named in
+// Principal.Roles, and "role:tag" scopes a role to the jobs with that tag, e.g. "operator:billing" may only run,
+// pause and see billing jobs. Jobs a caller may not see are left out of the job list and WebSocket updates and
+// answered with 404. Authorization needs WithAuthenticator, without it every request is denied.
+func WithAuthorization(roles ...Role) Option {
+	return func(s *Server) {
+		s.authz = newAuthorizer(roles)
+	}
+}
+
+// authorizer resolves the roles of callers to what they are allowed to do
+type authorizer struct {
+	roles map[string]Role
+}
+
+func newAuthorizer(roles []Role) *authorizer {
+	a := &authorizer{roles: make(map[string]Role, len(builtinRoles)+len(roles))}
+	for _, role := range builtinRoles {
+		a.roles[role.Name] = role
+	}
+	for _, role := range roles {
+		a.roles[role.Name] = role
+	}
+	return a
+}
+
+// grants returns the roles of a caller which include a permission, scoped as the caller holds them
+func (a *authorizer) grants(p *Principal, perm Permission) []Role {
+	if p == nil {
+		return nil
+	}
+
+	var grants []Role
+	for _, ref := range p.Roles {
+		name, tag, scoped := strings.Cut(ref, ":")
+		role, ok := a.roles[name]
+		if !ok || !slices.Contains(role.Permissions, perm) {
+			continue
+		}
+		if scoped {
+			// a scope narrows the role, it cannot widen a role which is scoped already
+			if len(role.Tags) > 0 && !slices.Contains(role.Tags, tag) {
+				continue
+			}
+			role.Tags = []string{tag}
+		}
+		if len(role.Tags) > 0 && slices.Contains(globalPermissions, perm) {
+			continue
+		}
+		grants = append(grants, role)
+	}
+	return grants
+}
+
+// can reports whether a caller has a permission for at least some jobs
+func (a *authorizer) can(p *Principal, perm Permission) bool {
+	return len(a.grants(p, perm)) > 0
+}
+
+// canJob reports whether a caller has a permission for a job with the given tags
+func (a *authorizer) canJob(p *Principal, perm Permission, tags []string) bool {
+	for _, role := range a.grants(p, perm) {
+		if len(role.Tags) == 0 {
+			return true
+		}
+		for _, tag := range tags {
+			if slices.Contains(role.Tags, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jobPermissions lists the permissions a caller has for a job with the given tags
+func (a *authorizer) jobPermissions(p *Principal, tags []string) []Permission {
+	perms := make([]Permission, 0, len(jobPermissions))
+	for _, perm := range jobPermissions {
+		if a.canJob(p, perm, tags) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+// authorize wraps the handler of a route with a permission check. On routes with a job ID the check is made
+// against the tags of the job, jobs the caller may not see are answered with 404 as if they did not exist.
+// Routes without a job ID only need the permission for some jobs and filter what they return.
+func (s *Server) authorize(perm Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authz == nil {
+			next(w, r)
+			return
+		}
+
+		p, _ := PrincipalFromContext(r.Context())
+		if !s.authz.can(p, perm) {
+			respondError(w, http.StatusForbidden, fmt.Sprintf("Permission denied: %s", perm))
+			return
+		}
+
+		if idStr, ok := mux.Vars(r)["id"]; ok {
+			id, err := uuid.Parse(idStr)
+			if err != nil {
+				// the handler answers with the usual error
+				next(w, r)
+				return
+			}
+			if tags, found := s.jobTags(id); found {
+				if !s.authz.canJob(p, PermissionView, tags) {
+					respondError(w, http.StatusNotFound, "Job not found")
+					return
+				}
+				if !s.authz.canJob(p, perm, tags) {
+					respondError(w, http.StatusForbidden, fmt.Sprintf("Permission denied: %s", perm))
+					return
+				}
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// authorizeTags checks that the caller may give a job the tags it is created or updated with.
+// It writes the error response and returns false if not.
+func (s *Server) authorizeTags(w http.ResponseWriter, r *http.Request, tags []string) bool {
+	if s.authz == nil {
+		return true
+	}
+	p, _ := PrincipalFromContext(r.Context())
+	if !s.authz.canJob(p, PermissionEdit, tags) {
+		respondError(w, http.StatusForbidden, "Permission denied: edit, the job's tags are outside of your roles")
+		return false
+	}
+	return true
+}
+
+// jobTags returns the tags of a scheduled or paused job
+func (s *Server) jobTags(id uuid.UUID) ([]string, bool) {
+	if mj, ok := s.managedJob(id); ok {
+		return mj.tags, true
+	}
+	if job, ok := s.findJob(id); ok {
+		return job.Tags(), true
+	}
+	return nil, false
+}
+
+// visibleJobs leaves out the jobs a caller may not see and adds what the caller may do with the others
+func (s *Server) visibleJobs(p *Principal, jobs []JobData) []JobData {
+	if s.authz == nil {
+		return jobs
+	}
+
+	visible := make([]JobData, 0, len(jobs))
+	for _, job := range jobs {
+		if !s.authz.canJob(p, PermissionView, job.Tags) {
+			continue
+		}
+		job.Permissions = s.authz.jobPermissions(p, job.Tags)
+		visible = append(visible, job)
+	}
+	return visible
+}
+
+// visibleRuns leaves out the executions of jobs a caller may not see
+func (s *Server) visibleRuns(p *Principal, runs []ActiveRun) []ActiveRun {
+	if s.authz == nil {
+		return runs
+	}
+
+	visible := make([]ActiveRun, 0, len(runs))
+	for _, run := range runs {
+		id, err := uuid.Parse(run.JobID)
+		if err != nil {
+			continue
+		}
+		tags, _ := s.jobTags(id)
+		if s.authz.canJob(p, PermissionView, tags) {
+			visible = append(visible, run)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// scopedServer has a billing and an ops job, and users whose password is their name:
+// admin, viewer, billing-op with operator:billing and billing-admin with admin:billing
+type scopedServer struct {
+	*Server
+	billing, ops uuid.UUID
+}
+
+func newScopedServer(t *testing.T) *scopedServer {
+	t.Helper()
+	users := Users{
+		{Name: "admin", Password: "admin", Roles: []string{RoleAdmin}},
+		{Name: "viewer", Password: "viewer", Roles: []string{RoleViewer}},
+		{Name: "billing-op", Password: "billing-op", Roles: []string{"operator:billing"}},
+		{Name: "billing-admin", Password: "billing-admin", Roles: []string{"admin:billing"}},
+	}
+	s, _ := newMonitoredServer(t,
+		WithAuthenticator(NewBasicAuthenticator("", users)),
+		WithAuthorization(),
+		WithTask("noop", func(context.Context, Params) error { return nil }, TaskSchema{}),
+	)
+
+	billing, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("invoices"), gocron.WithTags("billing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("backup"), gocron.WithTags("ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &scopedServer{Server: s, billing: billing.ID(), ops: ops.ID()}
+}
+
+// path replaces {billing} and {ops} with the IDs of the jobs
+func (s *scopedServer) path(template string) string {
+	return strings.NewReplacer("{billing}", s.billing.String(), "{ops}", s.ops.String()).Replace(template)
+}
+
+func (s *scopedServer) do(user, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, s.path(path), strings.NewReader(body))
+	req.SetBasicAuth(user, user)
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestScopedRoles(t *testing.T) {
+	s := newScopedServer(t)
+	create := func(tag string) string {
+		return fmt.Sprintf(`{"name":"%s report","type":"duration","interval":3600,"task":"noop","tags":[%q]}`, tag, tag)
+	}
+
+	// the cases run in order, pausing is undone by resuming
+	tests := []struct {
+		user, method, path, body string
+		want                     int
+	}{
+		{"viewer", "GET", "/api/jobs/{ops}", "", http.StatusOK},
+		{"viewer", "GET", "/api/jobs/{billing}/runs", "", http.StatusOK},
+		{"viewer", "POST", "/api/jobs/{billing}/run", "", http.StatusForbidden},
+		{"viewer", "POST", "/api/jobs/{billing}/pause", "", http.StatusForbidden},
+		{"viewer", "GET", "/api/audit", "", http.StatusForbidden},
+
+		// a scoped operator does not see the jobs outside of its tag, as if they did not exist
+		{"billing-op", "GET", "/api/jobs/{billing}", "", http.StatusOK},
+		{"billing-op", "GET", "/api/jobs/{ops}", "", http.StatusNotFound},
+		{"billing-op", "GET", "/api/jobs/{ops}/runs", "", http.StatusNotFound},
+		{"billing-op", "POST", "/api/jobs/{ops}/run", "", http.StatusNotFound},
+		{"billing-op", "POST", "/api/jobs/{ops}/pause", "", http.StatusNotFound},
+		{"billing-op", "POST", "/api/jobs/{ops}/resume", "", http.StatusNotFound},
+		{"billing-op", "POST", "/api/jobs/{ops}/runs/" + uuid.NewString() + "/cancel", "", http.StatusNotFound},
+		// without the permission for any job, the answer does not tell whether the job exists
+		{"billing-op", "PATCH", "/api/jobs/{ops}", `{"name":"mine"}`, http.StatusForbidden},
+		{"billing-op", "DELETE", "/api/jobs/{ops}", "", http.StatusForbidden},
+		{"billing-op", "POST", "/api/jobs/{billing}/run", "", http.StatusOK},
+		{"billing-op", "POST", "/api/jobs/{billing}/pause", "", http.StatusOK},
+		{"billing-op", "POST", "/api/jobs/{billing}/resume", "", http.StatusOK},
+		{"billing-op", "PATCH", "/api/jobs/{billing}", `{"name":"renamed"}`, http.StatusForbidden},
+		{"billing-op", "DELETE", "/api/jobs/{billing}", "", http.StatusForbidden},
+		{"billing-op", "POST", "/api/jobs", create("billing"), http.StatusForbidden},
+		// global permissions are never granted by a scoped role
+		{"billing-op", "GET", "/api/audit", "", http.StatusForbidden},
+		{"billing-op", "POST", "/api/scheduler/stop", "", http.StatusForbidden},
+
+		// a scoped admin edits only within its tag, and cannot move a job out of it
+		{"billing-admin", "PATCH", "/api/jobs/{ops}", `{"name":"mine"}`, http.StatusNotFound},
+		{"billing-admin", "PATCH", "/api/jobs/{billing}", `{"tags":["ops"]}`, http.StatusForbidden},
+		{"billing-admin", "PATCH", "/api/jobs/{billing}", `{"name":"invoices"}`, http.StatusOK},
+		{"billing-admin", "POST", "/api/jobs", create("ops"), http.StatusForbidden},
+		{"billing-admin", "POST", "/api/jobs", create("billing"), http.StatusCreated},
+		{"billing-admin", "GET", "/api/audit", "", http.StatusForbidden},
+		{"billing-admin", "POST", "/api/scheduler/stop", "", http.StatusForbidden},
+
+		{"admin", "GET", "/api/jobs/{ops}", "", http.StatusOK},
+		{"admin", "GET", "/api/audit", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := s.do(tt.user, tt.method, tt.path, tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s %s = %d, want %d: %s", tt.user, tt.method, tt.path, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	// calls on hidden jobs are audited without what the job looks like
+	rec := s.do("admin", "GET", "/api/audit?actor=billing-op&job={ops}", "")
+	var page struct {
+		Entries []AuditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("audit: %v: %s", err, rec.Body.String())
+	}
+	if len(page.Entries) == 0 {
+		t.Fatal("calls on the hidden job were not audited")
+	}
+	for _, entry := range page.Entries {
+		if entry.Outcome == AuditSuccess || len(entry.Changes) > 0 {
+			t.Errorf("audit entry %s %d has changes %+v", entry.Endpoint, entry.Status, entry.Changes)
+		}
+	}
+}
+
+func TestScopedLists(t *testing.T) {
+	s := newScopedServer(t)
+
+	rec := s.do("billing-op", "GET", "/api/jobs", "")
+	var jobs []JobData
+	if err := json.Unmarshal(rec.Body.Bytes(), &jobs); err != nil {
+		t.Fatalf("jobs: %v: %s", err, rec.Body.String())
+	}
+	if len(jobs) != 1 || jobs[0].ID != s.billing.String() {
+		t.Fatalf("billing-op sees %+v, want only the billing job", jobs)
+	}
+	if want := []Permission{PermissionView, PermissionRun, PermissionPause}; !slices.Equal(jobs[0].Permissions, want) {
+		t.Errorf("permissions = %v, want %v", jobs[0].Permissions, want)
+	}
+
+	rec = s.do("viewer", "GET", "/api/jobs", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || !slices.Equal(jobs[0].Permissions, []Permission{PermissionView}) {
+		t.Errorf("viewer sees %+v, want both jobs to view", jobs)
+	}
+
+	// a run of the ops job in progress
+	release := make(chan struct{})
+	defer close(release)
+	running, err := s.NewJob(DurationJob(time.Hour), NewTask(func() { <-release }), gocron.WithName("sync"),
+		gocron.WithTags("ops"), gocron.WithStartAt(gocron.WithStartImmediately()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runningIDs(s.monitor)[running.ID().String()] == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for user, want := range map[string]int{"billing-op": 0, "admin": 1} {
+		var runs []ActiveRun
+		if err := json.Unmarshal(s.do(user, "GET", "/api/running", "").Body.Bytes(), &runs); err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) != want {
+			t.Errorf("%s sees %d runs in progress, want %d", user, len(runs), want)
+		}
+	}
+}
+
+// streamMessages reads the messages of a stream until one is about a finished run of the job, failing the test
+// if the stream ends before
+func streamMessages(t *testing.T, next func() (string, error), jobID uuid.UUID) []string {
+	t.Helper()
+	var messages []string
+	for {
+		message, err := next()
+		if err != nil {
+			t.Fatalf("stream ended after %v: %v", messages, err)
+		}
+		messages = append(messages, message)
+		if strings.Contains(message, EventRunFinished) && strings.Contains(message, jobID.String()) {
+			return messages
+		}
+	}
+}
+
+// runBoth runs the ops job and then the billing job as admin, so that the events of the ops job come first
+func (s *scopedServer) runBoth(t *testing.T) {
+	t.Helper()
+	for _, path := range []string{"/api/jobs/{ops}/run", "/api/jobs/{billing}/run"} {
+		if rec := s.do("admin", "POST", path, ""); rec.Code != http.StatusOK {
+			t.Fatalf("run: %d %s", rec.Code, rec.Body.String())
+		}
+		// the ops job has finished before the billing job starts
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestScopedEventStream(t *testing.T) {
+	s := newScopedServer(t)
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("billing-op", "billing-op")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	next := func() (string, error) {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return "", err
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				return data, nil
+			}
+		}
+	}
+	s.runBoth(t)
+	for _, message := range streamMessages(t, next, s.billing) {
+		if strings.Contains(message, s.ops.String()) {
+			t.Errorf("billing-op got an event of the ops job: %s", message)
+		}
+	}
+}
+
+func TestScopedWebSocket(t *testing.T) {
+	s := newScopedServer(t)
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("billing-op:billing-op")))
+	dial := func(query string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws"+query, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+
+	// clients of version 1 get the job list
+	_, list, err := dial("").ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(list), s.billing.String()) || strings.Contains(string(list), s.ops.String()) {
+		t.Fatalf("job list = %s, want only the billing job", list)
+	}
+
+	conn := dial("?v=2")
+	next := func() (string, error) {
+		_, data, err := conn.ReadMessage()
+		return string(data), err
+	}
+	s.runBoth(t)
+	for _, message := range streamMessages(t, next, s.billing) {
+		if strings.Contains(message, s.ops.String()) {
+			t.Errorf("billing-op got an event of the ops job: %s", message)
+		}
+	}
+}
+
+func TestScopedEventVisibility(t *testing.T) {
+	s := newScopedServer(t)
+	p := &Principal{Name: "billing-op", Roles: []string{"operator:billing"}}
+	job := JobData{ID: uuid.NewString()}
+	events := []Event{
+		{Seq: 1, Type: EventJobUpdated, JobID: job.ID, Job: &job, tags: []string{"ops"}, previousTags: []string{"ops"}},
+		{Seq: 2, Type: EventRunStarted, JobID: job.ID, tags: []string{"ops"}},
+		{Seq: 3, Type: EventJobUpdated, JobID: job.ID, Job: &job, tags: []string{"billing"}, previousTags: []string{"ops"}},
+		{Seq: 4, Type: EventJobUpdated, JobID: job.ID, Job: &job, tags: []string{"billing"}, previousTags: []string{"billing"}},
+		{Seq: 5, Type: EventJobUpdated, JobID: job.ID, Job: &job, tags: []string{"ops"}, previousTags: []string{"billing"}},
+		{Seq: 6, Type: EventJobRemoved, JobID: job.ID, tags: []string{"ops"}},
+	}
+
+	// the job only shows up while it is tagged billing
+	var got []string
+	for _, event := range s.visibleEvents(p, events) {
+		got = append(got, fmt.Sprintf("%d %s", event.Seq, event.Type))
+	}
+	want := []string{"3 " + EventJobAdded, "4 " + EventJobUpdated, "5 " + EventJobRemoved}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
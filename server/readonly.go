@@ -0,0 +1,30 @@
+package server
+
+import "net/http"
+
+// WithReadOnly makes the server a dashboard which everyone may look at but nobody can change anything through.
+// Requests which would change jobs, runs, the scheduler or alert rules are answered with 403, and the UI hides
+// its action buttons.
+func WithReadOnly() Option {
+	return func(s *Server) {
+		s.config.ReadOnly = true
+	}
+}
+
+// rejectWrites is a mux middleware which answers every request that is not a read with 403. Calls of mutating
+// routes are recorded in the audit log as denied, since they never reach the audited handler.
+func (s *Server) rejectWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			h, audited := auditedRoute(r)
+			rec := &auditRecorder{ResponseWriter: w}
+			respondError(rec, http.StatusForbidden, "The server is read-only")
+			if audited {
+				s.auditRejected(h.action, r, rec)
+			}
+		}
+	})
+}
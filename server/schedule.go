@@ -0,0 +1,338 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// schedule types
+const (
+	ScheduleDuration = "duration"
+	ScheduleRandom   = "random"
+	ScheduleCron     = "cron"
+	ScheduleDaily    = "daily"
+	ScheduleWeekly   = "weekly"
+	ScheduleMonthly  = "monthly"
+	ScheduleOneTime  = "onetime"
+)
+
+// ScheduleSpec describes when a job runs. Unlike a gocron.JobDefinition it can be inspected,
+// which lets the UI show the real schedule of jobs registered with Server.NewJob.
+// Create it with one of DurationJob, DurationRandomJob, CronJob, DailyJob, WeeklyJob, MonthlyJob or OneTimeJob.
+type ScheduleSpec struct {
+	Type           string   `json:"type"`
+	Duration       string   `json:"duration,omitempty"`       // duration jobs, e.g. "10s"
+	MinDuration    string   `json:"minDuration,omitempty"`    // random jobs
+	MaxDuration    string   `json:"maxDuration,omitempty"`    // random jobs
+	CronExpression string   `json:"cronExpression,omitempty"` // cron jobs
+	WithSeconds    bool     `json:"withSeconds,omitempty"`    // cron jobs with a leading seconds field
+	Interval       uint     `json:"interval,omitempty"`       // daily, weekly and monthly jobs run every N days, weeks or months
+	AtTimes        []string `json:"atTimes,omitempty"`        // daily, weekly and monthly jobs, HH:MM:SS
+	Weekdays       []string `json:"weekdays,omitempty"`       // weekly jobs, e.g. "Monday"
+	DaysOfMonth    []int    `json:"daysOfMonth,omitempty"`    // monthly jobs, 1 to 31 or -1 to -31 counting from the end of the month
+	Times          []string `json:"times,omitempty"`          // one-time jobs, RFC3339, none runs the job immediately
+}
+
+// DurationJob runs a job at a fixed interval
+func DurationJob(d time.Duration) ScheduleSpec {
+	return ScheduleSpec{Type: ScheduleDuration, Duration: d.String()}
+}
+
+// DurationRandomJob runs a job at a random interval between minDuration and maxDuration
+func DurationRandomJob(minDuration, maxDuration time.Duration) ScheduleSpec {
+	return ScheduleSpec{Type: ScheduleRandom, MinDuration: minDuration.String(), MaxDuration: maxDuration.String()}
+}
+
+// CronJob runs a job on a crontab schedule
+func CronJob(crontab string, withSeconds bool) ScheduleSpec {
+	return ScheduleSpec{Type: ScheduleCron, CronExpression: crontab, WithSeconds: withSeconds}
+}
+
+// DailyJob runs a job every interval days at the given times of day (HH:MM or HH:MM:SS)
+func DailyJob(interval uint, atTimes ...string) ScheduleSpec {
+	return ScheduleSpec{Type: ScheduleDaily, Interval: interval, AtTimes: atTimes}
+}
+
+// WeeklyJob runs a job every interval weeks on the given weekdays at the given times of day (HH:MM or HH:MM:SS)
+func WeeklyJob(interval uint, weekdays []time.Weekday, atTimes ...string) ScheduleSpec {
+	days := make([]string, 0, len(weekdays))
+	for _, day := range weekdays {
+		days = append(days, day.String())
+	}
+	return ScheduleSpec{Type: ScheduleWeekly, Interval: interval, Weekdays: days, AtTimes: atTimes}
+}
+
+// MonthlyJob runs a job every interval months on the given days of the month at the given times of day (HH:MM or HH:MM:SS).
+// Negative days count from the end of the month, -1 being the last day.
+func MonthlyJob(interval uint, daysOfMonth []int, atTimes ...string) ScheduleSpec {
+	return ScheduleSpec{Type: ScheduleMonthly, Interval: interval, DaysOfMonth: daysOfMonth, AtTimes: atTimes}
+}
+
+// OneTimeJob runs a job once at each of the given times, or immediately if no time is given
+func OneTimeJob(times ...time.Time) ScheduleSpec {
+	formatted := make([]string, 0, len(times))
+	for _, t := range times {
+		formatted = append(formatted, t.Format(time.RFC3339))
+	}
+	return ScheduleSpec{Type: ScheduleOneTime, Times: formatted}
+}
+
+// definition builds the gocron job definition of the spec
+func (spec ScheduleSpec) definition() (gocron.JobDefinition, error) {
+	switch spec.Type {
+	case ScheduleDuration:
+		d, err := parsePositiveDuration(spec.Duration)
+		if err != nil {
+			return nil, invalidField("duration", "Duration "+err.Error())
+		}
+		return gocron.DurationJob(d), nil
+
+	case ScheduleRandom:
+		minDuration, err := parsePositiveDuration(spec.MinDuration)
+		if err != nil {
+			return nil, invalidField("minDuration", "Minimum duration "+err.Error())
+		}
+		maxDuration, err := parsePositiveDuration(spec.MaxDuration)
+		if err != nil {
+			return nil, invalidField("maxDuration", "Maximum duration "+err.Error())
+		}
+		if maxDuration < minDuration {
+			return nil, invalidField("maxDuration", "Maximum duration must not be less than the minimum duration")
+		}
+		return gocron.DurationRandomJob(minDuration, maxDuration), nil
+
+	case ScheduleCron:
+		if spec.CronExpression == "" {
+			return nil, invalidField("cronExpression", "Cron expression is required for cron jobs")
+		}
+		return gocron.CronJob(spec.CronExpression, spec.WithSeconds), nil
+
+	case ScheduleDaily:
+		if spec.Interval == 0 {
+			return nil, invalidField("interval", "Interval must be positive for daily jobs")
+		}
+		atTimes, err := parseAtTimes(spec.AtTimes)
+		if err != nil {
+			return nil, err
+		}
+		return gocron.DailyJob(spec.Interval, atTimes), nil
+
+	case ScheduleWeekly:
+		if spec.Interval == 0 {
+			return nil, invalidField("interval", "Interval must be positive for weekly jobs")
+		}
+		weekdays, err := parseWeekdays(spec.Weekdays)
+		if err != nil {
+			return nil, err
+		}
+		atTimes, err := parseAtTimes(spec.AtTimes)
+		if err != nil {
+			return nil, err
+		}
+		return gocron.WeeklyJob(spec.Interval, weekdays, atTimes), nil
+
+	case ScheduleMonthly:
+		if spec.Interval == 0 {
+			return nil, invalidField("interval", "Interval must be positive for monthly jobs")
+		}
+		days, err := parseDaysOfMonth(spec.DaysOfMonth)
+		if err != nil {
+			return nil, err
+		}
+		atTimes, err := parseAtTimes(spec.AtTimes)
+		if err != nil {
+			return nil, err
+		}
+		return gocron.MonthlyJob(spec.Interval, days, atTimes), nil
+
+	case ScheduleOneTime:
+		if len(spec.Times) == 0 {
+			return gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()), nil
+		}
+		times := make([]time.Time, 0, len(spec.Times))
+		for _, value := range spec.Times {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, invalidField("times", fmt.Sprintf("Invalid time %q. Use RFC3339", value))
+			}
+			times = append(times, t)
+		}
+		return gocron.OneTimeJob(gocron.OneTimeJobStartDateTimes(times...)), nil
+
+	default:
+		return nil, invalidField("type", fmt.Sprintf("Invalid schedule type %q", spec.Type))
+	}
+}
+
+// describe returns the human-readable schedule and its technical details
+func (spec ScheduleSpec) describe() (string, string) {
+	switch spec.Type {
+	case ScheduleDuration:
+		d, err := time.ParseDuration(spec.Duration)
+		if err != nil {
+			break
+		}
+		return describeInterval(d)
+
+	case ScheduleRandom:
+		return "Random interval", fmt.Sprintf("Random: %s-%s", spec.MinDuration, spec.MaxDuration)
+
+	case ScheduleCron:
+		if spec.WithSeconds {
+			return "Cron schedule", "Cron (with seconds): " + spec.CronExpression
+		}
+		return "Cron schedule", "Cron: " + spec.CronExpression
+
+	case ScheduleDaily:
+		schedule := "Daily"
+		if spec.Interval > 1 {
+			schedule = fmt.Sprintf("Every %d days", spec.Interval)
+		}
+		return schedule + " at " + strings.Join(spec.AtTimes, ", "),
+			fmt.Sprintf("Daily: interval %d, at %s", spec.Interval, strings.Join(spec.AtTimes, ", "))
+
+	case ScheduleWeekly:
+		days := make([]string, 0, len(spec.Weekdays))
+		for _, day := range spec.Weekdays {
+			if len(day) > 3 {
+				day = day[:3]
+			}
+			days = append(days, day)
+		}
+		schedule := "Weekly"
+		if spec.Interval > 1 {
+			schedule = fmt.Sprintf("Every %d weeks", spec.Interval)
+		}
+		return fmt.Sprintf("%s on %s at %s", schedule, strings.Join(days, ", "), strings.Join(spec.AtTimes, ", ")),
+			fmt.Sprintf("Weekly: interval %d, %s, at %s", spec.Interval, strings.Join(days, ", "), strings.Join(spec.AtTimes, ", "))
+
+	case ScheduleMonthly:
+		days := make([]string, 0, len(spec.DaysOfMonth))
+		for _, day := range spec.DaysOfMonth {
+			days = append(days, strconv.Itoa(day))
+		}
+		schedule := "Monthly"
+		if spec.Interval > 1 {
+			schedule = fmt.Sprintf("Every %d months", spec.Interval)
+		}
+		return fmt.Sprintf("%s on day %s at %s", schedule, strings.Join(days, ", "), strings.Join(spec.AtTimes, ", ")),
+			fmt.Sprintf("Monthly: interval %d, days %s, at %s", spec.Interval, strings.Join(days, ", "), strings.Join(spec.AtTimes, ", "))
+
+	case ScheduleOneTime:
+		if len(spec.Times) == 0 {
+			return "One time only", "OneTime: immediately"
+		}
+		return "One time only", "OneTime: " + strings.Join(spec.Times, ", ")
+	}
+
+	return "Scheduled", "Custom schedule"
+}
+
+// inferSchedule describes the schedule of a job which was registered directly on the scheduler,
+// so the only information available is the gap between its upcoming runs
+func inferSchedule(nextRuns []time.Time) (string, string) {
+	if len(nextRuns) >= 2 {
+		return describeInterval(nextRuns[1].Sub(nextRuns[0]))
+	}
+	return "Scheduled", "Custom schedule"
+}
+
+// describeInterval describes a fixed interval in the largest unit that divides it evenly
+func describeInterval(interval time.Duration) (string, string) {
+	units := []struct {
+		size  time.Duration
+		name  string
+		short string
+	}{
+		{24 * time.Hour, "day", "d"},
+		{time.Hour, "hour", "h"},
+		{time.Minute, "minute", "m"},
+		{time.Second, "second", "s"},
+	}
+
+	for _, unit := range units {
+		if interval < unit.size || interval%unit.size != 0 {
+			continue
+		}
+		n := int64(interval / unit.size)
+		if n == 1 {
+			return "Every " + unit.name, fmt.Sprintf("Duration: 1%s", unit.short)
+		}
+		return fmt.Sprintf("Every %d %ss", n, unit.name), fmt.Sprintf("Duration: %d%s", n, unit.short)
+	}
+
+	return "Every " + interval.String(), "Duration: " + interval.String()
+}
+
+func parsePositiveDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, errors.New("is required")
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration", value)
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+func parseAtTimes(values []string) (gocron.AtTimes, error) {
+	if len(values) == 0 {
+		return nil, invalidField("atTimes", "At least one time of day is required")
+	}
+	atTimes := make([]gocron.AtTime, 0, len(values))
+	for _, value := range values {
+		atTime, err := parseTime(value)
+		if err != nil {
+			return nil, invalidField("atTimes", fmt.Sprintf("Invalid time %q. Use HH:MM:SS", value))
+		}
+		atTimes = append(atTimes, atTime)
+	}
+	return gocron.NewAtTimes(atTimes[0], atTimes[1:]...), nil
+}
+
+func parseWeekdays(values []string) (gocron.Weekdays, error) {
+	if len(values) == 0 {
+		return nil, invalidField("weekdays", "At least one weekday is required")
+	}
+	weekdays := make([]time.Weekday, 0, len(values))
+	for _, value := range values {
+		weekday, ok := parseWeekday(value)
+		if !ok {
+			return nil, invalidField("weekdays", fmt.Sprintf("Invalid weekday %q", value))
+		}
+		weekdays = append(weekdays, weekday)
+	}
+	return gocron.NewWeekdays(weekdays[0], weekdays[1:]...), nil
+}
+
+// parseWeekday accepts full or three letter weekday names in any case
+func parseWeekday(value string) (time.Weekday, bool) {
+	value = strings.ToLower(value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func parseDaysOfMonth(values []int) (gocron.DaysOfTheMonth, error) {
+	if len(values) == 0 {
+		return nil, invalidField("daysOfMonth", "At least one day of the month is required")
+	}
+	for _, day := range values {
+		if day == 0 || day < -31 || day > 31 {
+			return nil, invalidField("daysOfMonth", fmt.Sprintf("Invalid day of the month %d. Use 1 to 31 or -1 to -31", day))
+		}
+	}
+	return gocron.NewDaysOfTheMonth(values[0], values[1:]...), nil
+}
@@ -3,12 +3,15 @@ package server
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
@@ -16,83 +19,233 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
-// Server is the main server struct which contains the scheduler, router, webSocket clients, webSocket mutex, upgrader, and config
+// Server is the main server struct which contains the scheduler, router, subscribers to the job updates, upgrader, config and run history
 type Server struct {
-	Scheduler gocron.Scheduler
-	Router    http.Handler
-	wsClients map[*websocket.Conn]bool
-	wsMutex   sync.RWMutex
-	upgrader  websocket.Upgrader
-	config    Config
+	Scheduler     gocron.Scheduler
+	Router        http.Handler
+	clients       map[*subscriber]struct{} // WebSocket and server-sent events clients
+	clientsMu     sync.RWMutex
+	upgrader      websocket.Upgrader
+	config        Config
+	monitor       *Monitor
+	history       HistoryStore
+	store         Store
+	retention     Retention
+	tasks         *TaskRegistry
+	manualRuns    map[uuid.UUID]int
+	triggers      map[uuid.UUID][]trace.SpanContext // spans of RunJob requests whose runs did not start yet
+	runCounts     map[uuid.UUID]uint                // runs of a job since it was registered with its current options
+	runsMutex     sync.Mutex
+	failures      map[string]int // failed runs in a row by job ID, loaded from the history on demand
+	failuresMutex sync.Mutex
+	jobs          map[uuid.UUID]*managedJob
+	jobsMutex     sync.RWMutex
+	jobLocks      map[uuid.UUID]*jobLock // see lockJob
+	jobLocksMutex sync.Mutex
+	refresh       chan struct{}
+	events        *eventHub
+
+	authenticators []Authenticator
+	authz          *authorizer
+	audit          AuditLog
+	metrics        *metrics
+	tracer         trace.Tracer
+	alerts         *alerting
+
+	schedulerStopped atomic.Bool             // set while the scheduler is stopped through the API
+	restoredPauses   map[uuid.UUID]PauseInfo // paused jobs loaded from the store which were not registered again yet, see expireRestoredPauses
+
+	port              int
+	basePath          string       // without a trailing slash, empty for the root
+	static            http.Handler // the frontend
+	corsConfig        *CORSConfig  // nil for the default
+	corsDisabled      bool
+	wsOrigins         *cors.Cors   // other origins which may open the WebSocket
+	publicURL         string       // set by WithPublicURL
+	publicOrigin      *url.URL     // the origin of publicURL
+	trustForwarded    bool         // set by WithForwardedHeaders
+	httpServer        *http.Server // set by ListenAndServe
+	lifecycleMutex    sync.Mutex
+	stopped           bool           // set by Shutdown
+	stopping          bool           // set by Shutdown under clientsMu, clients which connect later are closed
+	done              chan struct{}  // closed by Shutdown to stop the background goroutines
+	background        sync.WaitGroup // background goroutines and connected clients
+	shutdownScheduler bool
+	schedulerOnce     sync.Once
+	optionErrs        []error // errors of options which could not be applied, returned by NewServer
 }
 
 // Config is the server configuration in which user can set the title of the UI
 type Config struct {
-	Title string `json:"title"`
+	Title    string `json:"title"`
+	ReadOnly bool   `json:"readOnly"` // see WithReadOnly
 }
 
-// NewServer creates a new server instance
-func NewServer(scheduler gocron.Scheduler, _ int, opts ...Option) *Server {
+// NewServer creates a new server instance which serves on the given port with ListenAndServe.
+// Its background goroutines run until Shutdown is called.
+func NewServer(scheduler gocron.Scheduler, port int, opts ...Option) (*Server, error) {
 	s := &Server{
 		Scheduler: scheduler,
-		wsClients: make(map[*websocket.Conn]bool),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(_ *http.Request) bool {
-				return true // allow all origins for development
-			},
-		},
+		port:      port,
+		done:      make(chan struct{}),
+		clients:   make(map[*subscriber]struct{}),
+		events:    newEventHub(),
 		config: Config{
 			Title: "GoCron UI", // default title
 		},
+		manualRuns: make(map[uuid.UUID]int),
+		triggers:   make(map[uuid.UUID][]trace.SpanContext),
+		runCounts:  make(map[uuid.UUID]uint),
+		failures:   make(map[string]int),
+		tasks:      NewTaskRegistry(),
+		jobs:       make(map[uuid.UUID]*managedJob),
+		jobLocks:   make(map[uuid.UUID]*jobLock),
+		refresh:    make(chan struct{}, 1),
 	}
 
 	// apply options
 	for _, opt := range opts {
 		opt(s)
 	}
+	if err := errors.Join(s.optionErrs...); err != nil {
+		return nil, err
+	}
 
-	router := mux.NewRouter()
+	if s.history == nil {
+		s.history = s.newHistoryStore()
+	}
+	if s.audit == nil {
+		s.audit = s.newAuditLog()
+	}
 
-	// api routes
-	api := router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/config", s.GetConfig).Methods("GET")
-	api.HandleFunc("/jobs", s.GetJobs).Methods("GET")
-	api.HandleFunc("/jobs", s.CreateJob).Methods("POST")
-	api.HandleFunc("/jobs/{id}", s.GetJob).Methods("GET")
-	api.HandleFunc("/jobs/{id}", s.DeleteJob).Methods("DELETE")
-	api.HandleFunc("/jobs/{id}/run", s.RunJob).Methods("POST")
-	api.HandleFunc("/scheduler/stop", s.StopScheduler).Methods("POST")
-	api.HandleFunc("/scheduler/start", s.StartScheduler).Methods("POST")
+	// recreate the jobs which were created through the API before a restart, paused as they were
+	if s.store != nil {
+		s.restoredPauses = s.loadPauses()
+		s.restoreJobs()
+	}
 
-	// webSocket route
-	router.HandleFunc("/ws", s.HandleWebSocket)
+	if s.alerts != nil {
+		s.setupAlerts()
+	}
+
+	if s.authz != nil && len(s.authenticators) == 0 {
+		log.Printf("Authorization is enabled without an authenticator, every request will be denied")
+	}
 
 	// serve embedded static files (frontend)
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
-		log.Fatalf("Failed to load static files: %v", err)
+		return nil, fmt.Errorf("gocron-ui: loading static files: %w", err)
+	}
+	if s.static, err = newStaticHandler(staticFS, s.basePath); err != nil {
+		return nil, fmt.Errorf("gocron-ui: loading static files: %w", err)
 	}
-	router.PathPrefix("/").Handler(http.FileServer(http.FS(staticFS)))
+
+	router := mux.NewRouter()
+	s.Register(router)
 
 	// setup CORS
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
-	})
+	c, err := s.newCORS()
+	if err != nil {
+		return nil, err
+	}
+	s.Router = router
+	if c != nil {
+		s.Router = c.Handler(router)
+	}
+	// browsers do not apply CORS to WebSockets, the upgrader checks the origin itself
+	if err := s.parsePublicURL(); err != nil {
+		return nil, err
+	}
+	s.upgrader.CheckOrigin = s.checkOrigin
 
-	s.Router = c.Handler(router)
+	// start recording runs reported by the scheduler
+	if s.monitor != nil {
+		s.monitor.attach(s)
+	}
 
 	// start broadcasting job updates
-	go s.broadcastJobUpdates()
+	s.goBackground(s.broadcastJobUpdates)
+	if s.alerts != nil {
+		s.goBackground(s.watchAlerts)
+	}
+	if len(s.restoredPauses) > 0 {
+		s.goBackground(s.expireRestoredPauses)
+	}
+
+	return s, nil
+}
+
+// Register adds the routes of the UI, the API and the WebSocket under the base path to a router of your own,
+// together with the authentication, metrics and tracing middlewares, which only apply to these routes.
+// NewServer registers them on Router the same way and adds CORS on top.
+func (s *Server) Register(r *mux.Router) {
+	router := r.NewRoute().Subrouter()
+	if s.basePath != "" {
+		r.Path(s.basePath).Handler(http.RedirectHandler(s.basePath+"/", http.StatusMovedPermanently))
+		router = r.PathPrefix(s.basePath + "/").Subrouter()
+	}
+
+	// requests are timed and traced before authentication so that rejected ones show up as well
+	middlewares := []mux.MiddlewareFunc{s.storeBasePath}
+	if s.metrics != nil {
+		middlewares = append(middlewares, s.instrument)
+	}
+	if s.tracer != nil {
+		middlewares = append(middlewares, s.traceRequests)
+	}
+	router.Use(append(middlewares, s.auditUnauthenticated, s.requireAuth)...)
+
+	// api routes
+	api := router.PathPrefix("/api").Subrouter()
+	if s.config.ReadOnly {
+		api.Use(s.rejectWrites)
+	}
+	api.HandleFunc("/config", s.authorize(PermissionView, s.GetConfig)).Methods("GET")
+	api.HandleFunc("/jobs", s.authorize(PermissionView, s.GetJobs)).Methods("GET")
+	api.Handle("/jobs", s.audited(ActionJobCreate, s.authorize(PermissionEdit, s.CreateJob))).Methods("POST")
+	api.HandleFunc("/jobs/{id}", s.authorize(PermissionView, s.GetJob)).Methods("GET")
+	api.Handle("/jobs/{id}", s.audited(ActionJobUpdate, s.authorize(PermissionEdit, s.UpdateJob))).Methods("PUT")
+	api.Handle("/jobs/{id}", s.audited(ActionJobUpdate, s.authorize(PermissionEdit, s.PatchJob))).Methods("PATCH")
+	api.Handle("/jobs/{id}", s.audited(ActionJobDelete, s.authorize(PermissionDelete, s.DeleteJob))).Methods("DELETE")
+	api.Handle("/jobs/{id}/run", s.audited(ActionJobRun, s.authorize(PermissionRun, s.RunJob))).Methods("POST")
+	api.Handle("/jobs/{id}/pause", s.audited(ActionJobPause, s.authorize(PermissionPause, s.PauseJob))).Methods("POST")
+	api.Handle("/jobs/{id}/resume", s.audited(ActionJobResume, s.authorize(PermissionPause, s.ResumeJob))).Methods("POST")
+	api.HandleFunc("/jobs/{id}/runs", s.authorize(PermissionView, s.GetJobRuns)).Methods("GET")
+	api.Handle("/jobs/{id}/runs/{runId}/cancel", s.audited(ActionRunCancel, s.authorize(PermissionRun, s.CancelRun))).Methods("POST")
+	api.HandleFunc("/running", s.authorize(PermissionView, s.GetRunning)).Methods("GET")
+	api.HandleFunc("/tasks", s.authorize(PermissionView, s.GetTasks)).Methods("GET")
+	api.Handle("/scheduler/stop", s.audited(ActionSchedulerStop, s.authorize(PermissionScheduler, s.StopScheduler))).Methods("POST")
+	api.Handle("/scheduler/start", s.audited(ActionSchedulerStart, s.authorize(PermissionScheduler, s.StartScheduler))).Methods("POST")
+	api.HandleFunc("/audit", s.authorize(PermissionAudit, s.GetAudit)).Methods("GET")
+	api.HandleFunc("/events", s.authorize(PermissionView, s.HandleEvents)).Methods("GET")
+	if s.alerts != nil {
+		api.HandleFunc("/alerts", s.authorize(PermissionAlerts, s.GetAlerts)).Methods("GET")
+		api.Handle("/alerts", s.audited(ActionAlertCreate, s.authorize(PermissionAlerts, s.CreateAlert))).Methods("POST")
+		api.Handle("/alerts/{ruleId}", s.audited(ActionAlertUpdate, s.authorize(PermissionAlerts, s.UpdateAlert))).Methods("PUT")
+		api.Handle("/alerts/{ruleId}", s.audited(ActionAlertDelete, s.authorize(PermissionAlerts, s.DeleteAlert))).Methods("DELETE")
+		api.HandleFunc("/alerts/{ruleId}/test", s.authorize(PermissionAlerts, s.TestAlert)).Methods("POST")
+	}
+
+	// webSocket route
+	router.HandleFunc("/ws", s.authorize(PermissionView, s.HandleWebSocket))
 
-	return s
+	// prometheus metrics
+	if s.metrics != nil {
+		router.HandleFunc(s.metrics.cfg.Path, s.authorize(PermissionMetrics, s.GetMetrics)).Methods("GET")
+	}
+
+	// login routes, reachable without authentication
+	s.registerAuthRoutes(router)
+
+	// serve embedded static files (frontend)
+	router.PathPrefix("/").Handler(s.static)
 }
 
 // Option is a functional option for configuring the server
@@ -105,88 +258,55 @@ func WithTitle(title string) Option {
 	}
 }
 
-// GetConfig gets server configuration
-func (s *Server) GetConfig(w http.ResponseWriter, _ *http.Request) {
-	respondJSON(w, http.StatusOK, s.config)
+// WithBasePath serves the UI, the API and the WebSocket under a path such as /admin/scheduler instead of the root,
+// for mounting the server in another one
+func WithBasePath(path string) Option {
+	return func(s *Server) {
+		s.basePath = strings.TrimSuffix("/"+strings.Trim(path, "/"), "/")
+	}
 }
 
-// HandleWebSocket is a webSocket handler
-func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := s.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
-		return
+// WithMonitor records the runs reported by a monitor which was passed to the scheduler with gocron.WithMonitorStatus
+func WithMonitor(monitor *Monitor) Option {
+	return func(s *Server) {
+		s.monitor = monitor
 	}
-	defer conn.Close()
-
-	s.wsMutex.Lock()
-	s.wsClients[conn] = true
-	s.wsMutex.Unlock()
-
-	log.Printf("WebSocket client connected. Total clients: %d", len(s.wsClients))
+}
 
-	// send initial job list
-	jobs := s.getJobsData()
-	if err := conn.WriteJSON(map[string]interface{}{
-		"type": "jobs",
-		"data": jobs,
-	}); err != nil {
-		log.Printf("Error sending initial jobs: %v", err)
+// WithHistoryStore sets the store used for the run history, by default the most recent runs are kept in memory
+func WithHistoryStore(store HistoryStore) Option {
+	return func(s *Server) {
+		s.history = store
 	}
+}
 
-	// keep connection alive and handle client disconnection
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			s.wsMutex.Lock()
-			delete(s.wsClients, conn)
-			s.wsMutex.Unlock()
-			log.Printf("WebSocket client disconnected. Total clients: %d", len(s.wsClients))
-			break
-		}
+// WithStore persists the run history and jobs created through the API in a durable store, so that they survive restarts.
+// The store is not closed by the server.
+func WithStore(store Store) Option {
+	return func(s *Server) {
+		s.store = store
 	}
 }
 
-// broadcastJobUpdates broadcasts job updates to all connected webSocket clients
-func (s *Server) broadcastJobUpdates() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.wsMutex.RLock()
-		if len(s.wsClients) == 0 {
-			s.wsMutex.RUnlock()
-			continue
-		}
-		s.wsMutex.RUnlock()
-
-		jobs := s.getJobsData()
-		message := map[string]interface{}{
-			"type": "jobs",
-			"data": jobs,
-		}
-
-		s.wsMutex.RLock()
-		for client := range s.wsClients {
-			err := client.WriteJSON(message)
-			if err != nil {
-				log.Printf("Error broadcasting to client: %v", err)
-				s.wsMutex.RUnlock()
-				s.wsMutex.Lock()
-				delete(s.wsClients, client)
-				client.Close()
-				s.wsMutex.Unlock()
-				s.wsMutex.RLock()
-			}
-		}
-		s.wsMutex.RUnlock()
+// WithRetention limits how much run history is kept, by default the last 100 runs of each job are kept regardless of their age
+func WithRetention(retention Retention) Option {
+	return func(s *Server) {
+		s.retention = retention
 	}
 }
 
-// GetJobs gets all jobs
-func (s *Server) GetJobs(w http.ResponseWriter, _ *http.Request) {
-	jobs := s.getJobsData()
-	respondJSON(w, http.StatusOK, jobs)
+// GetConfig gets server configuration
+func (s *Server) GetConfig(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.config)
+}
+
+// notifyJobsChanged broadcasts the jobs right away instead of waiting for the next tick
+func (s *Server) notifyJobsChanged() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+		// a refresh is already pending
+	}
 }
 
 // GetJob gets a single job
@@ -200,16 +320,17 @@ func (s *Server) GetJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobs := s.Scheduler.Jobs()
-	for _, job := range jobs {
-		if job.ID() == id {
-			jobData := s.convertJobToData(job)
-			respondJSON(w, http.StatusOK, jobData)
-			return
-		}
+	jobData, ok := s.jobData(id)
+	if !ok {
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
 	}
 
-	respondError(w, http.StatusNotFound, "Job not found")
+	p, _ := PrincipalFromContext(r.Context())
+	if visible := s.visibleJobs(p, []JobData{jobData}); len(visible) == 1 {
+		jobData = visible[0]
+	}
+	respondJSON(w, http.StatusOK, jobData)
 }
 
 // CreateJob creates a new job
@@ -219,69 +340,13 @@ func (s *Server) CreateJob(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-
-	if req.Name == "" {
-		respondError(w, http.StatusBadRequest, "Job name is required")
-		return
-	}
-
-	// create job definition based on type
-	var jobDef gocron.JobDefinition
-	var err error
-
-	switch req.Type {
-	case "duration":
-		if req.Interval <= 0 {
-			respondError(w, http.StatusBadRequest, "Interval must be positive for duration jobs")
-			return
-		}
-		jobDef = gocron.DurationJob(time.Duration(req.Interval) * time.Second)
-
-	case "cron":
-		if req.CronExpression == "" {
-			respondError(w, http.StatusBadRequest, "Cron expression is required for cron jobs")
-			return
-		}
-		jobDef = gocron.CronJob(req.CronExpression, false)
-
-	case "daily":
-		if req.Interval <= 0 {
-			respondError(w, http.StatusBadRequest, "Interval must be positive for daily jobs")
-			return
-		}
-		if req.AtTime == "" {
-			respondError(w, http.StatusBadRequest, "AtTime is required for daily jobs")
-			return
-		}
-		atTime, err := parseTime(req.AtTime)
-		if err != nil {
-			respondError(w, http.StatusBadRequest, "Invalid time format. Use HH:MM:SS")
-			return
-		}
-		jobDef = gocron.DailyJob(uint(req.Interval), gocron.NewAtTimes(atTime))
-
-	default:
-		respondError(w, http.StatusBadRequest, "Invalid job type. Supported: duration, cron, daily")
+	if !s.authorizeTags(w, r, req.Tags) {
 		return
 	}
 
-	// create the task (example: just logs the job name)
-	task := gocron.NewTask(func() {
-		log.Printf("Executing job: %s", req.Name)
-	})
-
-	// create job options
-	options := []gocron.JobOption{
-		gocron.WithName(req.Name),
-	}
-	if len(req.Tags) > 0 {
-		options = append(options, gocron.WithTags(req.Tags...))
-	}
-
-	// add job to scheduler
-	job, err := s.Scheduler.NewJob(jobDef, task, options...)
+	job, err := s.createJob(req, uuid.Nil)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondJobError(w, err)
 		return
 	}
 
@@ -300,10 +365,18 @@ func (s *Server) DeleteJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.Scheduler.RemoveJob(id); err != nil { // remove job from scheduler using the job ID & RemoveJob is a method of the Scheduler interface
+	unlock := s.lockJob(id)
+	defer unlock()
+
+	if mj, ok := s.managedJob(id); ok && mj.paused != nil {
+		// paused jobs are not in the scheduler anymore
+	} else if err := s.Scheduler.RemoveJob(id); err != nil { // remove job from scheduler using the job ID & RemoveJob is a method of the Scheduler interface
 		respondError(w, http.StatusNotFound, "Job not found")
 		return
 	}
+	s.unregisterJob(id)
+	s.forgetJob(id)
+	s.notifyJobsChanged()
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Job deleted successfully"})
 }
@@ -319,19 +392,35 @@ func (s *Server) RunJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobs := s.Scheduler.Jobs()
-	for _, job := range jobs {
-		if job.ID() == id {
-			if err := job.RunNow(); err != nil {
-				respondError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			respondJSON(w, http.StatusOK, map[string]string{"message": "Job executed"})
+	job, ok := s.findJob(id)
+	if !ok {
+		if mj, ok := s.managedJob(id); ok && mj.paused != nil {
+			respondError(w, http.StatusConflict, "Job is paused")
 			return
 		}
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
 	}
 
-	respondError(w, http.StatusNotFound, "Job not found")
+	s.markManualRun(id)
+	s.markTrigger(r.Context(), id)
+	if err := job.RunNow(); err != nil {
+		s.takeManualRun(id)
+		s.takeTrigger(id)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Job executed"})
+}
+
+// GetRunning lists the executions of all jobs which are in progress, oldest first
+func (s *Server) GetRunning(w http.ResponseWriter, r *http.Request) {
+	if s.monitor == nil {
+		respondJSON(w, http.StatusOK, []ActiveRun{})
+		return
+	}
+	p, _ := PrincipalFromContext(r.Context())
+	respondJSON(w, http.StatusOK, s.visibleRuns(p, s.monitor.Running()))
 }
 
 // StopScheduler stops the scheduler
@@ -340,12 +429,14 @@ func (s *Server) StopScheduler(w http.ResponseWriter, _ *http.Request) {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.schedulerStopped.Store(true)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Scheduler stopped"})
 }
 
 // StartScheduler starts the scheduler
 func (s *Server) StartScheduler(w http.ResponseWriter, _ *http.Request) {
 	s.Scheduler.Start()
+	s.schedulerStopped.Store(false)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Scheduler started"})
 }
 
@@ -356,7 +447,7 @@ func (s *Server) getJobsData() []JobData {
 	for _, job := range jobs {
 		result = append(result, s.convertJobToData(job))
 	}
-	return result
+	return append(result, s.pausedJobsData()...)
 }
 
 func (s *Server) convertJobToData(job gocron.Job) JobData {
@@ -366,97 +457,45 @@ func (s *Server) convertJobToData(job gocron.Job) JobData {
 	// get next 5 runs
 	nextRuns, _ := job.NextRuns(5)
 
-	// determine schedule info based on job name patterns or intervals
-	schedule, scheduleDetail := s.inferSchedule(job, nextRuns)
-
-	return JobData{
-		ID:             job.ID().String(),
-		Name:           job.Name(),
-		Tags:           job.Tags(),
-		NextRun:        formatTime(nextRun),
-		LastRun:        formatTime(lastRun),
-		NextRuns:       formatTimes(nextRuns),
-		Schedule:       schedule,
-		ScheduleDetail: scheduleDetail,
+	jobData := JobData{
+		ID:       job.ID().String(),
+		Name:     job.Name(),
+		Tags:     job.Tags(),
+		NextRun:  formatTime(nextRun),
+		LastRun:  formatTime(lastRun),
+		NextRuns: formatTimes(nextRuns),
 	}
-}
 
-func (s *Server) inferSchedule(job gocron.Job, nextRuns []time.Time) (string, string) {
-	name := job.Name()
-
-	// try to infer from job name
-	if len(name) > 0 {
-		// check for common patterns in name
-		if strings.Contains(name, "every") || strings.Contains(name, "interval") {
-			if strings.Contains(name, "10s") || strings.Contains(name, "10-s") {
-				return "Every 10 seconds", "Duration: 10s"
-			}
-			if strings.Contains(name, "5s") {
-				return "Every 5 seconds", "Duration: 5s"
-			}
-			if strings.Contains(name, "minute") {
-				return "Every minute", "Duration: 1m"
-			}
-		}
-
-		if strings.Contains(name, "cron") {
-			return "Cron schedule", "Cron: * * * * *"
-		}
-
-		if strings.Contains(name, "daily") {
-			return "Daily", "Daily schedule"
-		}
-
-		if strings.Contains(name, "weekly") {
-			return "Weekly", "Weekly: Mon, Wed, Fri"
-		}
-
-		if strings.Contains(name, "random") {
-			return "Random interval", "Random: 5-15s"
-		}
-
-		if strings.Contains(name, "singleton") {
-			return "Every 5 seconds (singleton)", "Duration: 5s, Mode: Singleton"
-		}
-
-		if strings.Contains(name, "limited") {
-			return "Every 7 seconds (limited)", "Duration: 7s, Max runs: 3"
-		}
-
-		if strings.Contains(name, "parameter") {
-			return "Every 12 seconds", "Duration: 12s"
-		}
-
-		if strings.Contains(name, "context") {
-			return "Every 8 seconds", "Duration: 8s"
-		}
-
-		if strings.Contains(name, "one-time") || strings.Contains(name, "onetime") {
-			return "One time only", "OneTime job"
+	// jobs registered through the server know their schedule, for all others it is inferred from the upcoming runs
+	if mj, ok := s.managedJob(job.ID()); ok {
+		spec := mj.spec
+		jobData.ScheduleSpec = &spec
+		if mj.request != nil {
+			jobData.Options = mj.request.Options.copy()
 		}
+		jobData.Schedule, jobData.ScheduleDetail = spec.describe()
+	} else {
+		jobData.Schedule, jobData.ScheduleDetail = inferSchedule(nextRuns)
 	}
+	s.setRunning(&jobData, job.ID())
+	jobData.ConsecutiveFailures = s.consecutiveFailures(jobData.ID)
 
-	// try to infer from next runs interval
-	if len(nextRuns) >= 2 {
-		interval := nextRuns[1].Sub(nextRuns[0])
+	return jobData
+}
 
-		if interval < time.Minute {
-			seconds := int(interval.Seconds())
-			return fmt.Sprintf("Every %d seconds", seconds), fmt.Sprintf("Duration: %ds", seconds)
-		}
-		if interval < time.Hour {
-			minutes := int(interval.Minutes())
-			return fmt.Sprintf("Every %d minutes", minutes), fmt.Sprintf("Duration: %dm", minutes)
-		}
-		if interval < 24*time.Hour {
-			hours := int(interval.Hours())
-			return fmt.Sprintf("Every %d hours", hours), fmt.Sprintf("Duration: %dh", hours)
-		}
-		days := int(interval.Hours() / 24)
-		return fmt.Sprintf("Every %d days", days), fmt.Sprintf("Duration: %dd", days)
+// setRunning fills in whether the job is running right now, which is only known with a monitor
+func (s *Server) setRunning(jobData *JobData, id uuid.UUID) {
+	if s.monitor == nil {
+		return
 	}
-
-	return "Scheduled", "Custom schedule"
+	runs := s.monitor.runningOf(id)
+	if len(runs) == 0 {
+		return
+	}
+	jobData.Running = true
+	jobData.RunningSince = formatTime(runs[0].StartedAt)
+	jobData.RunningCount = len(runs)
+	jobData.ActiveRuns = runs
 }
 
 func formatTime(t time.Time) string {
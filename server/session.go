@@ -0,0 +1,202 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultSessionTTL is how long a session lasts unless SessionConfig.TTL is set
+const DefaultSessionTTL = 12 * time.Hour
+
+const defaultSessionCookie = "gocron_ui_session"
+
+// SessionConfig configures a SessionAuthenticator
+type SessionConfig struct {
+	// Secret signs the session cookies. It must be at least 32 bytes long and stay the same across restarts
+	// for sessions to survive them.
+	Secret []byte
+	// Passwords checks the login form, nil disables the password login, e.g. when sessions are started by an OIDC login
+	Passwords PasswordChecker
+	// TTL is how long a session lasts, zero falls back to DefaultSessionTTL
+	TTL time.Duration
+	// CookieName is the name of the session cookie, empty falls back to "gocron_ui_session"
+	CookieName string
+	// Secure only sends the cookie over HTTPS
+	Secure bool
+}
+
+// SessionAuthenticator authenticates requests with a signed session cookie, which it sets after a login on the login page.
+// Sessions are stateless, the cookie carries the user and its expiry signed with HMAC-SHA256.
+type SessionAuthenticator struct {
+	cfg SessionConfig
+}
+
+var (
+	_ RouteAuthenticator = (*SessionAuthenticator)(nil)
+	_ LoginProvider      = (*SessionAuthenticator)(nil)
+)
+
+// sessionPayload is the signed content of a session cookie
+type sessionPayload struct {
+	Name    string   `json:"n"`
+	Roles   []string `json:"r,omitempty"`
+	Expires int64    `json:"e"`
+}
+
+// NewSessionAuthenticator creates a session authenticator
+func NewSessionAuthenticator(cfg SessionConfig) (*SessionAuthenticator, error) {
+	if len(cfg.Secret) < 32 {
+		return nil, errors.New("gocron-ui: session secret must be at least 32 bytes long")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultSessionTTL
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = defaultSessionCookie
+	}
+	return &SessionAuthenticator{cfg: cfg}, nil
+}
+
+// Authenticate checks the session cookie of a request. Missing, expired and tampered cookies are all treated
+// as no session, so that the caller is sent to the login page.
+func (a *SessionAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	cookie, err := r.Cookie(a.cfg.CookieName)
+	if err != nil {
+		return nil, nil
+	}
+
+	var payload sessionPayload
+	if !a.verify(purposeSession, cookie.Value, &payload) || time.Now().Unix() >= payload.Expires {
+		return nil, nil
+	}
+	return &Principal{Name: payload.Name, Roles: payload.Roles}, nil
+}
+
+// StartSession logs a user in by setting the session cookie on the response, scoped to the server's base path
+func (a *SessionAuthenticator) StartSession(w http.ResponseWriter, r *http.Request, p Principal) error {
+	expires := time.Now().Add(a.cfg.TTL)
+	value, err := a.sign(purposeSession, sessionPayload{Name: p.Name, Roles: p.Roles, Expires: expires.Unix()})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cfg.CookieName,
+		Value:    value,
+		Path:     cookiePath(r),
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.cfg.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// EndSession logs a user out by removing the session cookie
+func (a *SessionAuthenticator) EndSession(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cfg.CookieName,
+		Value:    "",
+		Path:     cookiePath(r),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.cfg.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// RegisterRoutes adds the login and logout endpoints
+func (a *SessionAuthenticator) RegisterRoutes(router *mux.Router) {
+	if a.cfg.Passwords != nil {
+		router.HandleFunc("/login", a.Login).Methods("POST")
+	}
+	router.HandleFunc("/logout", a.Logout).Methods("POST")
+}
+
+// LoginMethods offers the password login if a PasswordChecker is configured
+func (a *SessionAuthenticator) LoginMethods() []LoginMethod {
+	if a.cfg.Passwords == nil {
+		return nil
+	}
+	return []LoginMethod{{Type: LoginPassword, Label: "Log in", URL: "/auth/login"}}
+}
+
+// LoginRequest represents the body of a password login
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Login checks a username and password and starts a session
+func (a *SessionAuthenticator) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	p, err := a.cfg.Passwords.CheckPassword(req.Username, req.Password)
+	if err != nil || p == nil {
+		respondError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if err := a.StartSession(w, r, *p); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, p)
+}
+
+// Logout ends the session
+func (a *SessionAuthenticator) Logout(w http.ResponseWriter, r *http.Request) {
+	a.EndSession(w, r)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// purposes of signed values, a value signed for one purpose is rejected for all others
+const (
+	purposeSession   = "session"
+	purposeOIDCLogin = "oidc-login"
+)
+
+// sign encodes a value as JSON and signs it for a purpose, so that it can be handed to the browser in a cookie
+func (a *SessionAuthenticator) sign(purpose string, v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(a.mac(purpose, encoded)), nil
+}
+
+// verify checks the signature of a value created by sign for the same purpose and decodes it into v
+func (a *SessionAuthenticator) verify(purpose, value string, v any) bool {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	given, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(given, a.mac(purpose, encoded)) {
+		return false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func (a *SessionAuthenticator) mac(purpose, data string) []byte {
+	h := hmac.New(sha256.New, a.cfg.Secret)
+	h.Write([]byte(purpose + ":" + data))
+	return h.Sum(nil)
+}
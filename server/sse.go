@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sseRetry is how long browsers wait before they reconnect
+	sseRetry = 3 * time.Second
+	// ssePingPeriod is how often a comment is sent, so that proxies do not close an idle stream
+	ssePingPeriod = 30 * time.Second
+)
+
+// HandleEvents streams the events of version 2 of the WebSocket protocol as server-sent events, for clients
+// behind proxies which do not pass WebSockets. Every message is an event named by its type with the message
+// as JSON data. Events carry an ID of the form stream:seq, so browsers resume with the Last-Event-ID header
+// when they reconnect. The first connection may resume with ?lastEventId=.
+func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	filter, err := parseJobFilter(r.URL.Query())
+	if err != nil {
+		respondJobError(w, err)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var resume *eventPosition
+	if stream, seq, found := strings.Cut(lastEventID, ":"); found {
+		if since, err := strconv.ParseUint(seq, 10, 64); err == nil {
+			resume = &eventPosition{stream: stream, seq: since}
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no") // nginx would buffer the stream otherwise
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	flusher.Flush()
+
+	p, _ := PrincipalFromContext(r.Context())
+	client := newSubscriber(transportSSE, p, ProtocolVersion, filter)
+	total := s.subscribe(client, resume)
+	log.Printf("Event stream client connected. Total clients: %d", total)
+	defer func() {
+		total := s.unsubscribe(client)
+		log.Printf("Event stream client disconnected. Total clients: %d", total)
+	}()
+
+	// the server's write timeout, if any, would end the stream, every write gets its own deadline
+	rc := http.NewResponseController(w)
+	stream := ""
+	if resume != nil {
+		stream = resume.stream
+	}
+
+	ticker := time.NewTicker(ssePingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case messages := <-client.send:
+			_ = rc.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			for _, message := range messages {
+				if err := writeEvent(w, &stream, message); err != nil {
+					log.Printf("Error writing to event stream client: %v", err)
+					return
+				}
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-client.closing:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a message as a server-sent event. Snapshots and events get an ID, the stream of the IDs is
+// the one of the last snapshot or resumed message.
+func writeEvent(w http.ResponseWriter, stream *string, message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	var name, id string
+	switch m := message.(type) {
+	case SnapshotMessage:
+		*stream = m.Stream
+		name, id = m.Type, fmt.Sprintf("%s:%d", m.Stream, m.Seq)
+	case ResumedMessage:
+		*stream = m.Stream
+		name = m.Type
+	case Event:
+		name, id = m.Type, fmt.Sprintf("%s:%d", *stream, m.Seq)
+	default:
+		name = "message"
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// pages of the frontend by their path, they are rendered with the base path which the scripts build their URLs from
+var pages = map[string]string{
+	"/":           "index.html",
+	"/index.html": "index.html",
+	"/login.html": "login.html",
+}
+
+// staticHandler serves the embedded frontend below the base path
+type staticHandler struct {
+	basePath string
+	files    http.Handler
+	pages    map[string][]byte
+}
+
+func newStaticHandler(files fs.FS, basePath string) (*staticHandler, error) {
+	h := &staticHandler{
+		basePath: basePath,
+		files:    http.StripPrefix(basePath, http.FileServer(http.FS(files))),
+		pages:    make(map[string][]byte, len(pages)),
+	}
+	for path, name := range pages {
+		tmpl, err := template.ParseFS(files, name)
+		if err != nil {
+			return nil, err
+		}
+		var page bytes.Buffer
+		if err := tmpl.Execute(&page, struct{ BasePath string }{basePath}); err != nil {
+			return nil, err
+		}
+		h.pages[path] = page.Bytes()
+	}
+	return h, nil
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if page, ok := h.pages[strings.TrimPrefix(r.URL.Path, h.basePath)]; ok {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(page)
+		return
+	}
+	h.files.ServeHTTP(w, r)
+}
@@ -1,25 +1,47 @@
 // State
 let jobs = [];
 let ws = null;
+let eventSource = null; // server-sent events, used when the WebSocket cannot be opened
 let isConnected = false;
 let expandedSchedules = new Set(); // Track which job schedules are expanded
-
-// API Base URL
-const API_BASE = window.location.origin + '/api';
-const WS_URL = `ws://${window.location.host}/ws`;
+let tasks = []; // tasks registered on the server which new jobs can run
+let readOnly = false; // the server rejects every change, the UI only shows the jobs
+let stream = null; // the event stream of the server, to resume after reconnecting
+let lastSeq = null; // sequence number of the last event applied to jobs
+let renderPending = false;
+
+// API Base URL, the server may be mounted below a base path
+const BASE_PATH = document.querySelector('meta[name="gocron-base-path"]').content;
+const API_BASE = window.location.origin + BASE_PATH + '/api';
+const AUTH_BASE = window.location.origin + BASE_PATH + '/auth';
+const WS_URL = `${window.location.protocol === 'https:' ? 'wss' : 'ws'}://${window.location.host}${BASE_PATH}/ws?v=2`;
+const EVENT_TYPES = ['snapshot', 'resumed', 'jobAdded', 'jobUpdated', 'jobRemoved', 'runStarted', 'runFinished'];
 
 // initialize on page load
 document.addEventListener('DOMContentLoaded', () => {
     loadConfig();
+    loadCurrentUser();
+    loadTasks();
     connectWebSocket();
+    document.getElementById('jobs-container').addEventListener('click', handleJobAction);
 });
 
 // load server configuration
 async function loadConfig() {
     try {
         const response = await fetch(`${API_BASE}/config`);
+        if (response.status === 401) {
+            // the session expired or was never started
+            window.location.href = `${BASE_PATH}/login.html`;
+            return;
+        }
         if (response.ok) {
             const config = await response.json();
+            if (config.readOnly) {
+                readOnly = true;
+                updateNewJobButton();
+                scheduleRender();
+            }
             if (config.title) {
                 // update page title
                 document.title = config.title;
@@ -43,12 +65,60 @@ async function loadConfig() {
     }
 }
 
+// show who is logged in, the server answers 404 when authentication is not enabled
+async function loadCurrentUser() {
+    try {
+        const response = await fetch(`${AUTH_BASE}/me`);
+        if (response.ok) {
+            const user = await response.json();
+            document.getElementById('current-user-name').textContent = user.name;
+            document.getElementById('current-user').style.display = 'flex';
+        }
+    } catch (err) {
+        console.error('Failed to load current user:', err);
+    }
+}
+
+async function logout() {
+    try {
+        await fetch(`${AUTH_BASE}/logout`, { method: 'POST' });
+    } catch (err) {
+        console.error('Failed to log out:', err);
+    }
+    window.location.href = `${BASE_PATH}/login.html`;
+}
+
+// job creation is only offered when the server has tasks and accepts changes
+function updateNewJobButton() {
+    document.getElementById('new-job-btn').style.display = tasks.length > 0 && !readOnly ? 'inline-block' : 'none';
+}
+
+// load the tasks new jobs can run
+async function loadTasks() {
+    try {
+        const response = await fetch(`${API_BASE}/tasks`);
+        if (response.ok) {
+            tasks = await response.json() || [];
+            updateNewJobButton();
+        }
+    } catch (err) {
+        console.error('Failed to load tasks:', err);
+    }
+}
+
 // webSocket connection
 function connectWebSocket() {
-    ws = new WebSocket(WS_URL);
+    let url = WS_URL;
+    if (stream !== null && lastSeq !== null) {
+        // only the events missed while disconnected are sent, or a snapshot if they are gone
+        url += `&stream=${encodeURIComponent(stream)}&since=${lastSeq}`;
+    }
+    ws = new WebSocket(url);
+    let opened = false;
 
     ws.onopen = () => {
         console.log('WebSocket connected');
+        opened = true;
         isConnected = true;
         updateConnectionStatus(true);
         hideError();
@@ -56,11 +126,7 @@ function connectWebSocket() {
 
     ws.onmessage = (event) => {
         try {
-            const message = JSON.parse(event.data);
-            if (message.type === 'jobs') {
-                jobs = message.data || [];
-                renderJobs();
-            }
+            handleMessage(JSON.parse(event.data));
         } catch (err) {
             console.error('Failed to parse WebSocket message:', err);
         }
@@ -75,11 +141,103 @@ function connectWebSocket() {
         console.log('WebSocket disconnected');
         isConnected = false;
         updateConnectionStatus(false);
+        if (!opened && window.EventSource) {
+            // a proxy which does not pass WebSockets, stream the events over plain HTTP instead
+            console.log('WebSocket unavailable, falling back to server-sent events');
+            connectEventSource();
+            return;
+        }
         // reconnect after 3 seconds
         setTimeout(connectWebSocket, 3000);
     };
 }
 
+// server-sent events connection, the browser reconnects and resumes with Last-Event-ID by itself
+function connectEventSource() {
+    let url = `${API_BASE}/events`;
+    if (stream !== null && lastSeq !== null) {
+        url += `?lastEventId=${encodeURIComponent(`${stream}:${lastSeq}`)}`;
+    }
+    eventSource = new EventSource(url);
+
+    eventSource.onopen = () => {
+        console.log('Event stream connected');
+        isConnected = true;
+        updateConnectionStatus(true);
+        hideError();
+    };
+
+    EVENT_TYPES.forEach(type => {
+        eventSource.addEventListener(type, (event) => {
+            try {
+                handleMessage(JSON.parse(event.data));
+            } catch (err) {
+                console.error('Failed to parse event:', err);
+            }
+        });
+    });
+
+    eventSource.onerror = () => {
+        isConnected = false;
+        updateConnectionStatus(false);
+        if (eventSource.readyState === EventSource.CLOSED) {
+            // the server refused the stream, the browser does not retry that by itself
+            showError('Connection error. Retrying...');
+            setTimeout(connectEventSource, 3000);
+        }
+    };
+}
+
+// apply a message of the WebSocket protocol or the event stream to the jobs
+function handleMessage(message) {
+    switch (message.type) {
+        case 'snapshot':
+            stream = message.stream;
+            lastSeq = message.seq;
+            jobs = message.jobs || [];
+            break;
+        case 'resumed':
+            stream = message.stream;
+            break;
+        case 'jobAdded':
+        case 'jobUpdated': {
+            const index = jobs.findIndex(job => job.id === message.jobId);
+            if (index >= 0) {
+                jobs[index] = message.job;
+            } else {
+                jobs.push(message.job);
+            }
+            break;
+        }
+        case 'jobRemoved':
+            jobs = jobs.filter(job => job.id !== message.jobId);
+            expandedSchedules.delete(message.jobId);
+            break;
+        case 'runStarted':
+        case 'runFinished':
+            // the job's running state arrives with jobUpdated
+            break;
+        default:
+            return;
+    }
+    if (message.seq !== undefined && message.type !== 'resumed') {
+        lastSeq = message.seq;
+    }
+    scheduleRender();
+}
+
+// render once per frame, a tick can bring many events
+function scheduleRender() {
+    if (renderPending) {
+        return;
+    }
+    renderPending = true;
+    requestAnimationFrame(() => {
+        renderPending = false;
+        renderJobs();
+    });
+}
+
 function updateConnectionStatus(connected) {
     const statusEl = document.getElementById('connection-status');
     if (connected) {
@@ -114,6 +272,19 @@ async function deleteJob(id) {
     }
 }
 
+async function createJob(job) {
+    const response = await fetch(`${API_BASE}/jobs`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(job),
+    });
+
+    if (!response.ok) {
+        const body = await response.json().catch(() => ({}));
+        throw new Error(body.error || 'Failed to create job');
+    }
+}
+
 async function runJob(id) {
     const response = await fetch(`${API_BASE}/jobs/${id}/run`, {
         method: 'POST',
@@ -124,6 +295,41 @@ async function runJob(id) {
     }
 }
 
+async function pauseJob(id, reason) {
+    const response = await fetch(`${API_BASE}/jobs/${id}/pause`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ reason: reason }),
+    });
+
+    if (!response.ok) {
+        const body = await response.json().catch(() => ({}));
+        throw new Error(body.error || 'Failed to pause job');
+    }
+}
+
+async function resumeJob(id) {
+    const response = await fetch(`${API_BASE}/jobs/${id}/resume`, {
+        method: 'POST',
+    });
+
+    if (!response.ok) {
+        const body = await response.json().catch(() => ({}));
+        throw new Error(body.error || 'Failed to resume job');
+    }
+}
+
+async function cancelRun(jobId, runId) {
+    const response = await fetch(`${API_BASE}/jobs/${jobId}/runs/${runId}/cancel`, {
+        method: 'POST',
+    });
+
+    if (!response.ok) {
+        const body = await response.json().catch(() => ({}));
+        throw new Error(body.error || 'Failed to cancel run');
+    }
+}
+
 // job actions
 async function handleRunJob(id) {
     try {
@@ -134,6 +340,42 @@ async function handleRunJob(id) {
     }
 }
 
+async function handleCancelRun(jobId, runId, name) {
+    if (!confirm(`Cancel the running execution of "${name}"?`)) {
+        return;
+    }
+
+    try {
+        await cancelRun(jobId, runId);
+        hideError();
+    } catch (err) {
+        showError(err.message);
+    }
+}
+
+async function handlePauseJob(id, name) {
+    const reason = prompt(`Pause job "${name}"? Optionally enter a reason:`, '');
+    if (reason === null) {
+        return;
+    }
+
+    try {
+        await pauseJob(id, reason.trim());
+        hideError();
+    } catch (err) {
+        showError(err.message);
+    }
+}
+
+async function handleResumeJob(id) {
+    try {
+        await resumeJob(id);
+        hideError();
+    } catch (err) {
+        showError(err.message);
+    }
+}
+
 async function handleDeleteJob(id, name) {
     if (!confirm(`Are you sure you want to delete job "${name}"?`)) {
         return;
@@ -146,6 +388,215 @@ async function handleDeleteJob(id, name) {
     }
 }
 
+// create job form
+function openJobModal() {
+    document.getElementById('job-form').reset();
+    document.getElementById('form-error').style.display = 'none';
+    document.getElementById('job-task').innerHTML = tasks.map(task =>
+        `<option value="${escapeHtml(task.name)}">${escapeHtml(task.name)}</option>`
+    ).join('');
+    renderTaskParams();
+    renderScheduleFields();
+    document.getElementById('job-modal').style.display = 'flex';
+}
+
+function closeJobModal() {
+    document.getElementById('job-modal').style.display = 'none';
+}
+
+function selectedTask() {
+    const name = document.getElementById('job-task').value;
+    return tasks.find(task => task.name === name);
+}
+
+function renderTaskParams() {
+    const task = selectedTask();
+    document.getElementById('job-task-description').textContent = task && task.description ? task.description : '';
+    document.getElementById('job-params').innerHTML = task ? task.params.map(param => {
+        const id = `param-${escapeHtml(param.name)}`;
+        const label = `${escapeHtml(param.name)}${param.required ? ' *' : ''}`;
+        const value = param.default !== undefined ? param.default : '';
+        const input = param.type === 'bool'
+            ? `<select id="${id}">
+                   <option value=""></option>
+                   <option value="true" ${value === true ? 'selected' : ''}>true</option>
+                   <option value="false" ${value === false ? 'selected' : ''}>false</option>
+               </select>`
+            : `<input id="${id}" type="${param.type === 'int' || param.type === 'float' ? 'number' : 'text'}"
+                   ${param.type === 'float' ? 'step="any"' : ''} value="${escapeHtml(String(value))}"
+                   ${param.required ? 'required' : ''}>`;
+        return `
+            <div class="form-group">
+                <label for="${id}">${label}</label>
+                ${input}
+                <small>${escapeHtml(param.type)}${param.description ? ` · ${escapeHtml(param.description)}` : ''}</small>
+            </div>
+        `;
+    }).join('') : '';
+}
+
+function renderScheduleFields() {
+    const type = document.getElementById('job-type').value;
+    const atTimes = `
+        <div class="form-group">
+            <label for="job-at-times">At times</label>
+            <input id="job-at-times" type="text" placeholder="09:00, 17:30" required>
+            <small>Comma separated, HH:MM or HH:MM:SS</small>
+        </div>
+    `;
+    const fields = {
+        duration: `
+            <div class="form-group">
+                <label for="job-interval">Interval (seconds)</label>
+                <input id="job-interval" type="number" min="1" required>
+            </div>
+        `,
+        random: `
+            <div class="form-group">
+                <label for="job-min-interval">Minimum interval (seconds)</label>
+                <input id="job-min-interval" type="number" min="1" required>
+            </div>
+            <div class="form-group">
+                <label for="job-max-interval">Maximum interval (seconds)</label>
+                <input id="job-max-interval" type="number" min="1" required>
+            </div>
+        `,
+        cron: `
+            <div class="form-group">
+                <label for="job-cron">Cron expression</label>
+                <input id="job-cron" type="text" placeholder="*/5 * * * *" required>
+            </div>
+        `,
+        daily: `
+            <div class="form-group">
+                <label for="job-interval">Every N days</label>
+                <input id="job-interval" type="number" min="1" value="1" required>
+            </div>
+            ${atTimes}
+        `,
+        weekly: `
+            <div class="form-group">
+                <label for="job-interval">Every N weeks</label>
+                <input id="job-interval" type="number" min="1" value="1" required>
+            </div>
+            <div class="form-group">
+                <label for="job-weekdays">Weekdays</label>
+                <input id="job-weekdays" type="text" placeholder="mon, wed, fri" required>
+                <small>Comma separated</small>
+            </div>
+            ${atTimes}
+        `,
+        monthly: `
+            <div class="form-group">
+                <label for="job-interval">Every N months</label>
+                <input id="job-interval" type="number" min="1" value="1" required>
+            </div>
+            <div class="form-group">
+                <label for="job-days">Days of the month</label>
+                <input id="job-days" type="text" placeholder="1, 15, -1" required>
+                <small>Comma separated, negative days count from the end of the month</small>
+            </div>
+            ${atTimes}
+        `,
+        onetime: `
+            <div class="form-group">
+                <label for="job-times">Run at</label>
+                <input id="job-times" type="datetime-local" step="1" required>
+            </div>
+        `,
+    };
+    document.getElementById('job-schedule-fields').innerHTML = fields[type] || '';
+}
+
+function readTaskParams(task) {
+    const params = {};
+    task.params.forEach(param => {
+        const raw = document.getElementById(`param-${param.name}`).value;
+        if (raw === '') {
+            return;
+        }
+        switch (param.type) {
+            case 'int':
+            case 'float':
+                params[param.name] = Number(raw);
+                break;
+            case 'bool':
+                params[param.name] = raw === 'true';
+                break;
+            default:
+                params[param.name] = raw;
+        }
+    });
+    return params;
+}
+
+async function handleCreateJob(event) {
+    event.preventDefault();
+
+    const task = selectedTask();
+    const type = document.getElementById('job-type').value;
+    const job = {
+        name: document.getElementById('job-name').value.trim(),
+        type: type,
+        task: task ? task.name : '',
+        params: task ? readTaskParams(task) : {},
+        tags: splitList(document.getElementById('job-tags').value),
+    };
+    if (['duration', 'daily', 'weekly', 'monthly'].includes(type)) {
+        job.interval = Number(document.getElementById('job-interval').value);
+    }
+    if (type === 'random') {
+        job.minInterval = Number(document.getElementById('job-min-interval').value);
+        job.maxInterval = Number(document.getElementById('job-max-interval').value);
+    }
+    if (type === 'cron') {
+        job.cronExpression = document.getElementById('job-cron').value.trim();
+    }
+    if (['daily', 'weekly', 'monthly'].includes(type)) {
+        job.atTimes = splitList(document.getElementById('job-at-times').value);
+    }
+    if (type === 'weekly') {
+        job.weekdays = splitList(document.getElementById('job-weekdays').value);
+    }
+    if (type === 'monthly') {
+        job.daysOfMonth = splitList(document.getElementById('job-days').value).map(Number);
+    }
+    if (type === 'onetime') {
+        const at = toRFC3339(document.getElementById('job-times').value);
+        job.times = at ? [at] : [];
+    }
+
+    const options = {
+        singletonMode: document.getElementById('job-singleton').value,
+        limitedRuns: Number(document.getElementById('job-limited-runs').value) || 0,
+        startImmediately: document.getElementById('job-start-immediately').checked,
+        startAt: toRFC3339(document.getElementById('job-start-at').value),
+        stopAt: toRFC3339(document.getElementById('job-stop-at').value),
+    };
+    if (Object.values(options).some(value => value)) {
+        job.options = options;
+    }
+
+    try {
+        await createJob(job);
+        closeJobModal();
+    } catch (err) {
+        const formError = document.getElementById('form-error');
+        formError.textContent = err.message;
+        formError.style.display = 'block';
+    }
+}
+
+// datetime-local inputs are in the browser's time zone, the API expects RFC3339
+function toRFC3339(value) {
+    const date = new Date(value);
+    return value && !isNaN(date) ? date.toISOString() : '';
+}
+
+function splitList(value) {
+    return value.split(',').map(item => item.trim()).filter(item => item);
+}
+
 // rendering
 function renderJobs() {
     const container = document.getElementById('jobs-container');
@@ -199,33 +650,87 @@ function renderJobCard(job) {
     const timeUntil = job.nextRun ? getTimeUntil(job.nextRun) : '';
 
     return `
-        <div class="job-card">
+        <div class="job-card ${job.paused ? 'paused' : ''} ${job.running ? 'running' : ''}">
             <div class="job-card-header">
                 <h3 class="job-name">${escapeHtml(job.name)}</h3>
                 <div class="job-actions">
-                    <button
-                        class="btn btn-success btn-sm"
-                        onclick="handleRunJob('${job.id}')"
-                        title="Run now"
-                    >
-                        ▶️
-                    </button>
-                    <button
-                        class="btn btn-danger btn-sm"
-                        onclick="handleDeleteJob('${job.id}', '${escapeHtml(job.name)}')"
-                        title="Delete"
-                    >
-                        🗑️
-                    </button>
+                    ${job.paused ? `
+                        ${can(job, 'pause') ? `
+                            <button
+                                class="btn btn-success btn-sm"
+                                data-action="resume" data-job-id="${escapeHtml(job.id)}"
+                                title="Resume"
+                            >
+                                ⏯️
+                            </button>
+                        ` : ''}
+                    ` : `
+                        ${can(job, 'run') ? `
+                            <button
+                                class="btn btn-success btn-sm"
+                                data-action="run" data-job-id="${escapeHtml(job.id)}"
+                                title="Run now"
+                            >
+                                ▶️
+                            </button>
+                        ` : ''}
+                        ${can(job, 'pause') ? `
+                            <button
+                                class="btn btn-secondary btn-sm"
+                                data-action="pause" data-job-id="${escapeHtml(job.id)}" data-job-name="${escapeHtml(job.name)}"
+                                title="Pause"
+                            >
+                                ⏸️
+                            </button>
+                        ` : ''}
+                    `}
+                    ${can(job, 'delete') ? `
+                        <button
+                            class="btn btn-danger btn-sm"
+                            data-action="delete" data-job-id="${escapeHtml(job.id)}" data-job-name="${escapeHtml(job.name)}"
+                            title="Delete"
+                        >
+                            🗑️
+                        </button>
+                    ` : ''}
                 </div>
             </div>
 
+            ${job.running ? `
+                <div class="running-banner">
+                    <span class="running-dot"></span>
+                    Running${job.runningCount > 1 ? ` (${job.runningCount} executions)` : ''} since ${formatDateTime(job.runningSince)}
+                    ${(job.activeRuns || []).filter(run => run.cancellable && can(job, 'run')).map(run => `
+                        <button
+                            class="btn btn-danger btn-sm btn-cancel-run"
+                            data-action="cancel-run" data-job-id="${escapeHtml(job.id)}" data-run-id="${escapeHtml(run.id)}" data-job-name="${escapeHtml(job.name)}"
+                            title="Cancel the execution started ${formatDateTime(run.startedAt)}"
+                        >
+                            ⏹️
+                        </button>
+                    `).join('')}
+                </div>
+            ` : ''}
+
+            ${job.paused ? `
+                <div class="paused-banner">
+                    ⏸️ Paused${job.pausedBy ? ` by ${escapeHtml(job.pausedBy)}` : ''}${job.pausedAt ? ` on ${formatDateTime(job.pausedAt)}` : ''}
+                    ${job.pauseReason ? `<div class="pause-reason">${escapeHtml(job.pauseReason)}</div>` : ''}
+                </div>
+            ` : ''}
+
             ${job.tags && job.tags.length > 0 ? `
                 <div class="job-tags">
                     ${job.tags.map(tag => `<span class="tag">🏷️ ${escapeHtml(tag)}</span>`).join('')}
                 </div>
             ` : ''}
 
+            ${job.options ? `
+                <div class="job-options-list">
+                    ${renderJobOptions(job.options).map(option => `<span class="job-option">${escapeHtml(option)}</span>`).join('')}
+                </div>
+            ` : ''}
+
             ${job.schedule ? `
                 <div class="job-info-item" style="margin-bottom: 1rem;">
                     <span class="job-info-label">Schedule:</span>
@@ -252,16 +757,16 @@ function renderJobCard(job) {
                 </div>
                 <div class="job-info-item">
                     <span class="job-info-label">Job ID:</span>
-                    <span class="job-info-value job-id">${job.id}</span>
+                    <span class="job-info-value job-id">${escapeHtml(job.id)}</span>
                 </div>
             </div>
 
             ${job.nextRuns && job.nextRuns.length > 0 ? `
                 <div class="job-schedule">
-                    <button class="schedule-toggle" onclick="toggleSchedule('${job.id}')">
-                        <span id="toggle-icon-${job.id}">${expandedSchedules.has(job.id) ? '🔽' : '▶️'}</span> Upcoming Runs
+                    <button class="schedule-toggle" data-action="toggle-schedule" data-job-id="${escapeHtml(job.id)}">
+                        <span id="toggle-icon-${escapeHtml(job.id)}">${expandedSchedules.has(job.id) ? '🔽' : '▶️'}</span> Upcoming Runs
                     </button>
-                    <div id="schedule-${job.id}" class="schedule-details" style="display: ${expandedSchedules.has(job.id) ? 'block' : 'none'};">
+                    <div id="schedule-${escapeHtml(job.id)}" class="schedule-details" style="display: ${expandedSchedules.has(job.id) ? 'block' : 'none'};">
                         ${job.nextRuns.map(run => `
                             <div class="schedule-item">📌 ${formatDateTime(run)}</div>
                         `).join('')}
@@ -272,6 +777,63 @@ function renderJobCard(job) {
     `;
 }
 
+// dispatches the clicks on the buttons of the job cards, which carry the job in data attributes
+// instead of inline handlers so that no job name ever ends up in code
+function handleJobAction(event) {
+    const button = event.target.closest('[data-action]');
+    if (!button) return;
+
+    const { action, jobId, jobName, runId } = button.dataset;
+    switch (action) {
+        case 'run':
+            handleRunJob(jobId);
+            break;
+        case 'pause':
+            handlePauseJob(jobId, jobName);
+            break;
+        case 'resume':
+            handleResumeJob(jobId);
+            break;
+        case 'delete':
+            handleDeleteJob(jobId, jobName);
+            break;
+        case 'cancel-run':
+            handleCancelRun(jobId, runId, jobName);
+            break;
+        case 'toggle-schedule':
+            toggleSchedule(jobId);
+            break;
+    }
+}
+
+// whether the user may do something with a job, the server only lists permissions when it enforces them
+function can(job, permission) {
+    if (readOnly && permission !== 'view') {
+        return false;
+    }
+    return !job.permissions || job.permissions.includes(permission);
+}
+
+function renderJobOptions(options) {
+    const result = [];
+    if (options.singletonMode) {
+        result.push(`Singleton: ${options.singletonMode}`);
+    }
+    if (options.limitedRuns) {
+        result.push(`Limited to ${options.limitedRuns} runs`);
+    }
+    if (options.startImmediately) {
+        result.push('Started immediately');
+    }
+    if (options.startAt) {
+        result.push(`Starts ${formatDateTime(options.startAt)}`);
+    }
+    if (options.stopAt) {
+        result.push(`Stops ${formatDateTime(options.stopAt)}`);
+    }
+    return result;
+}
+
 function toggleSchedule(jobId) {
     const details = document.getElementById(`schedule-${jobId}`);
     const icon = document.getElementById(`toggle-icon-${jobId}`);
@@ -313,8 +875,12 @@ function getTimeUntil(dateStr) {
     return `in ${seconds}s`;
 }
 
+// escapes text for element content and quoted attribute values
 function escapeHtml(text) {
-    const div = document.createElement('div');
-    div.textContent = text;
-    return div.innerHTML;
+    return String(text ?? '')
+        .replace(/&/g, '&amp;')
+        .replace(/</g, '&lt;')
+        .replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;')
+        .replace(/'/g, '&#39;');
 }
@@ -0,0 +1,78 @@
+// login page, offers the login methods of the server's authenticators
+const BASE_PATH = document.querySelector('meta[name="gocron-base-path"]').content;
+const AUTH_BASE = window.location.origin + BASE_PATH + '/auth';
+
+let passwordLoginURL = null;
+
+document.addEventListener('DOMContentLoaded', () => {
+    loadLoginMethods();
+});
+
+// load the ways to log in, a password form and links to identity providers
+async function loadLoginMethods() {
+    try {
+        const response = await fetch(`${AUTH_BASE}/methods`);
+        if (!response.ok) {
+            throw new Error(`Failed to load login methods (${response.status})`);
+        }
+        const methods = await response.json() || [];
+
+        const redirects = document.getElementById('login-redirects');
+        redirects.innerHTML = '';
+        methods.forEach(method => {
+            if (method.type === 'password') {
+                passwordLoginURL = method.url;
+                document.getElementById('login-form').style.display = 'block';
+            } else if (method.type === 'redirect') {
+                const link = document.createElement('a');
+                link.className = 'btn btn-secondary';
+                link.href = method.url;
+                link.textContent = method.label || 'Log in';
+                redirects.appendChild(link);
+            }
+        });
+
+        if (methods.length === 0) {
+            showLoginError('No login method is configured on the server');
+        }
+    } catch (err) {
+        showLoginError(err.message);
+    }
+}
+
+async function handleLogin(event) {
+    event.preventDefault();
+    hideLoginError();
+
+    const submit = document.getElementById('login-submit');
+    submit.disabled = true;
+    try {
+        const response = await fetch(passwordLoginURL, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                username: document.getElementById('login-username').value,
+                password: document.getElementById('login-password').value,
+            }),
+        });
+        if (!response.ok) {
+            const error = await response.json().catch(() => ({}));
+            throw new Error(error.error || 'Login failed');
+        }
+        window.location.href = `${BASE_PATH}/`;
+    } catch (err) {
+        showLoginError(err.message);
+    } finally {
+        submit.disabled = false;
+    }
+}
+
+function showLoginError(message) {
+    const el = document.getElementById('login-error');
+    el.textContent = message;
+    el.style.display = 'block';
+}
+
+function hideLoginError() {
+    document.getElementById('login-error').style.display = 'none';
+}
@@ -0,0 +1,521 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// store buckets used by the server
+const (
+	bucketRuns      = "runs"
+	bucketJobs      = "jobs"
+	bucketPaused    = "paused"
+	bucketAudit     = "audit"
+	bucketAlerts    = "alerts"
+	bucketRunCounts = "runCounts"
+)
+
+// minCompactEntries is the log size below which the file store never compacts automatically
+const minCompactEntries = 1000
+
+// ErrStoreClosed is returned when a closed store is used
+var ErrStoreClosed = errors.New("gocron-ui: store is closed")
+
+// Store is a durable key/value backend for server state such as the run history and jobs created through the API.
+// Keys are grouped in buckets and values are opaque to the store.
+type Store interface {
+	// Put sets the value of a key in a bucket
+	Put(bucket, key string, value []byte) error
+	// Delete removes a key from a bucket, deleting a missing key is not an error
+	Delete(bucket, key string) error
+	// List returns every key of a bucket with its value
+	List(bucket string) (map[string][]byte, error)
+	// Compact reclaims the space used by overwritten and deleted entries
+	Compact() error
+	// Close flushes and releases the store
+	Close() error
+}
+
+// storeEntry is a single line of the file store's log
+type storeEntry struct {
+	Op     string `json:"op"` // put, del
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Value  []byte `json:"value,omitempty"`
+}
+
+// FileStore is a Store backed by an append-only JSON lines log which is kept in memory.
+// The log is rewritten with only the live entries once it holds twice as many entries as there are keys.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	data    map[string]map[string][]byte
+	entries int // number of lines in the log
+	closed  bool
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore opens or creates the file store at path.
+// A partially written last line, as left behind by a crash, is discarded.
+func NewFileStore(path string) (*FileStore, error) {
+	fs := &FileStore{
+		path: path,
+		data: make(map[string]map[string][]byte),
+	}
+
+	corrupt, err := fs.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if corrupt {
+		// rewrite the log so that new entries are not appended to a broken line
+		if err := fs.rewrite(); err != nil {
+			return nil, err
+		}
+		return fs, nil
+	}
+
+	fs.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("gocron-ui: opening store: %w", err)
+	}
+	return fs, nil
+}
+
+// load replays the log into memory and reports whether its tail was corrupt
+func (fs *FileStore) load() (bool, error) {
+	f, err := os.Open(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("gocron-ui: opening store: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry storeEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				if err == io.EOF {
+					// partially written last entry
+					return true, nil
+				}
+				return false, fmt.Errorf("gocron-ui: corrupt store entry %d: %w", fs.entries+1, jsonErr)
+			}
+			fs.apply(entry)
+			fs.entries++
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("gocron-ui: reading store: %w", err)
+		}
+	}
+}
+
+func (fs *FileStore) apply(entry storeEntry) {
+	switch entry.Op {
+	case "put":
+		bucket, ok := fs.data[entry.Bucket]
+		if !ok {
+			bucket = make(map[string][]byte)
+			fs.data[entry.Bucket] = bucket
+		}
+		bucket[entry.Key] = entry.Value
+	case "del":
+		delete(fs.data[entry.Bucket], entry.Key)
+	}
+}
+
+// Put sets the value of a key in a bucket
+func (fs *FileStore) Put(bucket, key string, value []byte) error {
+	return fs.write(storeEntry{Op: "put", Bucket: bucket, Key: key, Value: append([]byte(nil), value...)})
+}
+
+// Delete removes a key from a bucket
+func (fs *FileStore) Delete(bucket, key string) error {
+	fs.mu.Lock()
+	_, ok := fs.data[bucket][key]
+	fs.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return fs.write(storeEntry{Op: "del", Bucket: bucket, Key: key})
+}
+
+func (fs *FileStore) write(entry storeEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrStoreClosed
+	}
+	if fs.file == nil {
+		return errors.New("gocron-ui: store log is not open")
+	}
+	if _, err := fs.file.Write(line); err != nil {
+		return fmt.Errorf("gocron-ui: writing store: %w", err)
+	}
+	fs.apply(entry)
+	fs.entries++
+
+	if fs.entries >= minCompactEntries && fs.entries > 2*fs.liveEntries() {
+		// the entry itself is safely written, a failed compaction is retried on the next write
+		if err := fs.rewrite(); err != nil {
+			log.Printf("Error compacting store: %v", err)
+		}
+	}
+	return nil
+}
+
+// List returns every key of a bucket with its value
+func (fs *FileStore) List(bucket string) (map[string][]byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return nil, ErrStoreClosed
+	}
+	result := make(map[string][]byte, len(fs.data[bucket]))
+	for key, value := range fs.data[bucket] {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// Compact rewrites the log with only the live entries
+func (fs *FileStore) Compact() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrStoreClosed
+	}
+	return fs.rewrite()
+}
+
+// Close flushes the log to disk and closes it
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return nil
+	}
+	fs.closed = true
+	if fs.file == nil {
+		return nil
+	}
+	if err := fs.file.Sync(); err != nil {
+		fs.file.Close()
+		return err
+	}
+	return fs.file.Close()
+}
+
+func (fs *FileStore) liveEntries() int {
+	n := 0
+	for _, bucket := range fs.data {
+		n += len(bucket)
+	}
+	return n
+}
+
+// rewrite atomically replaces the log with the live entries, fs.mu must be held
+func (fs *FileStore) rewrite() error {
+	tmpPath := fs.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("gocron-ui: compacting store: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	entries := 0
+	for bucket, values := range fs.data {
+		for key, value := range values {
+			if err := enc.Encode(storeEntry{Op: "put", Bucket: bucket, Key: key, Value: value}); err != nil {
+				tmp.Close()
+				return fmt.Errorf("gocron-ui: compacting store: %w", err)
+			}
+			entries++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("gocron-ui: compacting store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("gocron-ui: compacting store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("gocron-ui: compacting store: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, fs.path); err != nil {
+		return fmt.Errorf("gocron-ui: compacting store: %w", err)
+	}
+
+	old := fs.file
+	fs.file, err = os.OpenFile(fs.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if old != nil {
+		old.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("gocron-ui: reopening store: %w", err)
+	}
+	fs.entries = entries
+	return nil
+}
+
+// persistJob stores the request of a job created through the API so that it can be recreated after a restart
+func (s *Server) persistJob(id uuid.UUID, req CreateJobRequest) {
+	if s.store == nil {
+		return
+	}
+
+	value, err := json.Marshal(req)
+	if err != nil {
+		log.Printf("Error encoding job %s: %v", id, err)
+		return
+	}
+	if err := s.store.Put(bucketJobs, id.String(), value); err != nil {
+		log.Printf("Error persisting job %s: %v", id, err)
+	}
+}
+
+// forgetJob removes a job from the store once it was deleted
+func (s *Server) forgetJob(id uuid.UUID) {
+	if s.store == nil {
+		return
+	}
+
+	if err := s.store.Delete(bucketJobs, id.String()); err != nil {
+		log.Printf("Error removing job %s from store: %v", id, err)
+	}
+	if err := s.store.Delete(bucketRunCounts, id.String()); err != nil {
+		log.Printf("Error removing run count of job %s from store: %v", id, err)
+	}
+	s.forgetPause(id)
+}
+
+// persistPause stores that a job is paused, so that it stays paused after a restart
+func (s *Server) persistPause(id uuid.UUID, info PauseInfo) {
+	if s.store == nil {
+		return
+	}
+
+	value, err := json.Marshal(info)
+	if err != nil {
+		log.Printf("Error encoding pause of job %s: %v", id, err)
+		return
+	}
+	if err := s.store.Put(bucketPaused, id.String(), value); err != nil {
+		log.Printf("Error persisting pause of job %s: %v", id, err)
+	}
+}
+
+// forgetPause removes the paused state of a job from the store
+func (s *Server) forgetPause(id uuid.UUID) {
+	if s.store == nil {
+		return
+	}
+
+	if err := s.store.Delete(bucketPaused, id.String()); err != nil {
+		log.Printf("Error removing pause of job %s from store: %v", id, err)
+	}
+}
+
+// countRun counts a run of a job. The count of a job with limited runs is persisted, so that the job only
+// gets the runs it has left when it is restored.
+func (s *Server) countRun(id uuid.UUID) {
+	s.runsMutex.Lock()
+	s.runCounts[id]++
+	runs := s.runCounts[id]
+	s.runsMutex.Unlock()
+
+	if s.store == nil {
+		return
+	}
+	if mj, ok := s.managedJob(id); !ok || mj.request == nil || mj.request.Options == nil || mj.request.Options.LimitedRuns == 0 {
+		return
+	}
+	if err := s.store.Put(bucketRunCounts, id.String(), []byte(strconv.FormatUint(uint64(runs), 10))); err != nil {
+		log.Printf("Error persisting run count of job %s: %v", id, err)
+	}
+}
+
+// runCount returns the runs of a job since it was registered with its current options
+func (s *Server) runCount(id uuid.UUID) uint {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+	return s.runCounts[id]
+}
+
+// resetRunCount starts counting the runs of a job from zero, once it is registered with new options or removed
+func (s *Server) resetRunCount(id uuid.UUID) {
+	s.runsMutex.Lock()
+	delete(s.runCounts, id)
+	s.runsMutex.Unlock()
+
+	if s.store == nil {
+		return
+	}
+	if err := s.store.Delete(bucketRunCounts, id.String()); err != nil {
+		log.Printf("Error removing run count of job %s from store: %v", id, err)
+	}
+}
+
+// loadRunCounts reads the run counts of the jobs with limited runs from the store
+func (s *Server) loadRunCounts() {
+	values, err := s.store.List(bucketRunCounts)
+	if err != nil {
+		log.Printf("Error loading run counts from store: %v", err)
+		return
+	}
+
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+	for key, value := range values {
+		id, err := uuid.Parse(key)
+		if err != nil {
+			continue
+		}
+		runs, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			log.Printf("Skipping unreadable run count of job %s: %v", id, err)
+			continue
+		}
+		s.runCounts[id] = uint(runs)
+	}
+}
+
+// loadPauses reads the paused state of jobs from the store.
+// Jobs are paused again as soon as they are registered with the same ID.
+func (s *Server) loadPauses() map[uuid.UUID]PauseInfo {
+	pauses := make(map[uuid.UUID]PauseInfo)
+
+	values, err := s.store.List(bucketPaused)
+	if err != nil {
+		log.Printf("Error loading paused jobs from store: %v", err)
+		return pauses
+	}
+	for key, value := range values {
+		id, err := uuid.Parse(key)
+		if err != nil {
+			log.Printf("Skipping paused job with invalid ID %q", key)
+			continue
+		}
+		var info PauseInfo
+		if err := json.Unmarshal(value, &info); err != nil {
+			log.Printf("Skipping unreadable pause of job %s: %v", id, err)
+			continue
+		}
+		pauses[id] = info
+	}
+	return pauses
+}
+
+// restoreJobs recreates the jobs which were created through the API before a restart.
+// They are not started right away again and only get the limited runs they have left.
+// Jobs which cannot be recreated are kept in the store so that they come back once the problem is fixed.
+func (s *Server) restoreJobs() {
+	s.loadRunCounts()
+
+	values, err := s.store.List(bucketJobs)
+	if err != nil {
+		log.Printf("Error loading jobs from store: %v", err)
+		return
+	}
+
+	existing := make(map[uuid.UUID]bool)
+	for _, job := range s.Scheduler.Jobs() {
+		existing[job.ID()] = true
+	}
+
+	for key, value := range values {
+		id, err := uuid.Parse(key)
+		if err != nil {
+			log.Printf("Skipping stored job with invalid ID %q", key)
+			continue
+		}
+		if existing[id] {
+			continue
+		}
+
+		var req CreateJobRequest
+		if err := json.Unmarshal(value, &req); err != nil {
+			log.Printf("Skipping unreadable stored job %s: %v", id, err)
+			continue
+		}
+		if req.Options.stopped(time.Now()) {
+			log.Printf("Dropping job %s (%s) whose stop time has passed", req.Name, id)
+			s.forgetJob(id)
+			continue
+		}
+		if req.Type == ScheduleOneTime {
+			// times which passed while the server was down are skipped
+			req.Times = futureTimes(req.Times, time.Now())
+			if len(req.Times) == 0 {
+				log.Printf("Dropping one-time job %s (%s) whose times have passed", req.Name, id)
+				s.forgetJob(id)
+				continue
+			}
+		}
+		if _, err := s.createJob(req, id); errors.Is(err, errNoRunsLeft) {
+			log.Printf("Dropping job %s (%s) which used up its runs", req.Name, id)
+			s.forgetJob(id)
+		} else if err != nil {
+			log.Printf("Error restoring job %s (%s): %v", req.Name, id, err)
+		}
+	}
+}
+
+// futureTimes returns the RFC3339 times which are after now, unparsable times are kept for validation to reject
+func futureTimes(values []string, now time.Time) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if t, err := time.Parse(time.RFC3339, value); err == nil && !t.After(now) {
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+// newHistoryStore creates the default history store, persisted when a store is configured
+func (s *Server) newHistoryStore() HistoryStore {
+	if s.store != nil {
+		history, err := newPersistentHistoryStore(s.store, s.retention)
+		if err == nil {
+			return history
+		}
+		log.Printf("Error loading run history from store, keeping it in memory only: %v", err)
+	}
+	return newHistoryStore(s.retention)
+}
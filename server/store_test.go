@@ -0,0 +1,256 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+func openStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// logLines counts the entries in the log of a file store
+func logLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func listed(t *testing.T, store Store, bucket string) map[string]string {
+	t.Helper()
+	values, err := store.List(bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		result[key] = string(value)
+	}
+	return result
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	store := openStore(t, path)
+	for _, step := range []func() error{
+		func() error { return store.Put("jobs", "a", []byte("1")) },
+		func() error { return store.Put("jobs", "b", []byte("2")) },
+		func() error { return store.Put("jobs", "a", []byte("3")) },
+		func() error { return store.Delete("jobs", "b") },
+		func() error { return store.Delete("jobs", "missing") },
+		func() error { return store.Put("runs", "a", []byte("4")) },
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// every change is appended, deleting a missing key writes nothing
+	if got := logLines(t, path); got != 5 {
+		t.Errorf("log has %d lines, want 5", got)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("jobs", "c", nil); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Put after Close = %v", err)
+	}
+
+	reopened := openStore(t, path)
+	if got := listed(t, reopened, "jobs"); len(got) != 1 || got["a"] != "3" {
+		t.Errorf("jobs = %v, want a=3", got)
+	}
+	if got := listed(t, reopened, "runs"); len(got) != 1 || got["a"] != "4" {
+		t.Errorf("runs = %v, want a=4", got)
+	}
+
+	if err := reopened.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := logLines(t, path); got != 2 {
+		t.Errorf("compacted log has %d lines, want 2", got)
+	}
+	if err := reopened.Put("jobs", "d", []byte("5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listed(t, openStore(t, path), "jobs"); len(got) != 2 || got["a"] != "3" || got["d"] != "5" {
+		t.Errorf("jobs after compaction = %v", got)
+	}
+}
+
+func TestFileStoreRewritesOverwrittenLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	store := openStore(t, path)
+	if err := store.Put("jobs", "kept", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	// one live key besides "kept", the log is rewritten once it reaches minCompactEntries
+	for i := 1; i < minCompactEntries-1; i++ {
+		if err := store.Put("runCounts", "job", []byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := logLines(t, path); got != minCompactEntries-1 {
+		t.Fatalf("log has %d lines before the rewrite, want %d", got, minCompactEntries-1)
+	}
+	if err := store.Put("runCounts", "job", []byte("last")); err != nil {
+		t.Fatal(err)
+	}
+	if got := logLines(t, path); got != 2 {
+		t.Errorf("log has %d lines after the rewrite, want the 2 live entries", got)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened := openStore(t, path)
+	if got := listed(t, reopened, "runCounts"); got["job"] != "last" {
+		t.Errorf("runCounts = %v", got)
+	}
+	if got := listed(t, reopened, "jobs"); got["kept"] != "x" {
+		t.Errorf("jobs = %v", got)
+	}
+}
+
+func TestFileStoreRecoversTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	store := openStore(t, path)
+	if err := store.Put("jobs", "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash in the middle of a write
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"op":"put","bucket":"jobs","key":"b","val`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	reopened := openStore(t, path)
+	if got := listed(t, reopened, "jobs"); len(got) != 1 || got["a"] != "1" {
+		t.Fatalf("jobs = %v, want the entry before the broken line", got)
+	}
+	// new entries are not appended to the broken line
+	if err := reopened.Put("jobs", "c", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listed(t, openStore(t, path), "jobs"); len(got) != 2 || got["c"] != "2" {
+		t.Errorf("jobs after recovery = %v", got)
+	}
+}
+
+func TestFileStoreRejectsCorruptEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	content := "{\"op\":\"put\",\"bucket\":\"jobs\",\"key\":\"a\"}\nnot json\n{\"op\":\"put\",\"bucket\":\"jobs\",\"key\":\"b\"}\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// only the last line can be left behind by a crash, a broken line before it is not skipped silently
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("opened a store with a corrupt entry")
+	}
+}
+
+func TestRestoreJobs(t *testing.T) {
+	store := openStore(t, filepath.Join(t.TempDir(), "store.log"))
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	jobs := map[string]CreateJobRequest{
+		"kept":       {Name: "kept", Type: ScheduleDuration, Interval: 3600, Task: "noop"},
+		"stopped":    {Name: "stopped", Type: ScheduleDuration, Interval: 3600, Task: "noop", Options: &JobOptions{StopAt: past}},
+		"passed":     {Name: "passed", Type: ScheduleOneTime, Times: []string{past}, Task: "noop"},
+		"one left":   {Name: "one left", Type: ScheduleOneTime, Times: []string{past, future}, Task: "noop"},
+		"used up":    {Name: "used up", Type: ScheduleDuration, Interval: 3600, Task: "noop", Options: &JobOptions{LimitedRuns: 2}},
+		"runs left":  {Name: "runs left", Type: ScheduleDuration, Interval: 3600, Task: "noop", Options: &JobOptions{LimitedRuns: 3}},
+		"no task":    {Name: "no task", Type: ScheduleDuration, Interval: 3600, Task: "missing"},
+		"paused job": {Name: "paused job", Type: ScheduleDuration, Interval: 3600, Task: "noop"},
+	}
+	ids := make(map[string]uuid.UUID, len(jobs))
+	persisted := &Server{store: store}
+	for name, req := range jobs {
+		ids[name] = uuid.New()
+		persisted.persistJob(ids[name], req)
+	}
+	for name, runs := range map[string]string{"used up": "2", "runs left": "1"} {
+		if err := store.Put(bucketRunCounts, ids[name].String(), []byte(runs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	persisted.persistPause(ids["paused job"], PauseInfo{Reason: "maintenance"})
+
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+	s, err := NewServer(scheduler, 0, WithStore(store),
+		WithTask("noop", func(context.Context, Params) error { return nil }, TaskSchema{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	for _, name := range []string{"kept", "one left", "runs left", "paused job"} {
+		if _, ok := s.managedJob(ids[name]); !ok {
+			t.Errorf("%s was not restored", name)
+		}
+	}
+	for _, name := range []string{"stopped", "passed", "used up", "no task"} {
+		if _, ok := s.managedJob(ids[name]); ok {
+			t.Errorf("%s was restored", name)
+		}
+	}
+	if mj, _ := s.managedJob(ids["paused job"]); mj == nil || mj.paused == nil || mj.paused.Reason != "maintenance" {
+		t.Errorf("paused job was not paused again")
+	}
+	if mj, _ := s.managedJob(ids["one left"]); mj == nil || len(mj.spec.Times) != 1 {
+		t.Errorf("one left was not restored with only its future time")
+	}
+	if got := s.runCount(ids["runs left"]); got != 1 {
+		t.Errorf("runs left has %d runs, want the persisted 1", got)
+	}
+
+	// dropped jobs are removed from the store, a job which cannot be restored yet is kept
+	stored := listed(t, store, bucketJobs)
+	for _, name := range []string{"stopped", "passed", "used up"} {
+		if _, ok := stored[ids[name].String()]; ok {
+			t.Errorf("%s is still stored", name)
+		}
+	}
+	if _, ok := stored[ids["no task"].String()]; !ok {
+		t.Error("no task was removed from the store")
+	}
+	if _, ok := listed(t, store, bucketRunCounts)[ids["used up"].String()]; ok {
+		t.Error("the run count of used up is still stored")
+	}
+}
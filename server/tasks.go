@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TaskFunc is a function which jobs created through the API can run, params are validated against the task's schema
+type TaskFunc func(ctx context.Context, params Params) error
+
+// ParamType is the type of a task parameter
+type ParamType string
+
+// supported parameter types
+const (
+	ParamString   ParamType = "string"
+	ParamInt      ParamType = "int"
+	ParamFloat    ParamType = "float"
+	ParamBool     ParamType = "bool"
+	ParamDuration ParamType = "duration" // Go duration string such as "1m30s"
+)
+
+// TaskParam describes a parameter of a task
+type TaskParam struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Required    bool      `json:"required"`
+	Default     any       `json:"default,omitempty"`
+	Description string    `json:"description,omitempty"`
+}
+
+// TaskSchema describes a task and the parameters it accepts
+type TaskSchema struct {
+	Description string      `json:"description,omitempty"`
+	Params      []TaskParam `json:"params"`
+}
+
+// TaskInfo is a registered task as listed by GET /api/tasks
+type TaskInfo struct {
+	Name string `json:"name"`
+	TaskSchema
+}
+
+// Params are the validated parameters passed to a TaskFunc.
+// Values have the Go type matching their ParamType: string, int, float64, bool or time.Duration.
+type Params map[string]any
+
+// String returns a string parameter, or "" if it is not set
+func (p Params) String(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+// Int returns an int parameter, or 0 if it is not set
+func (p Params) Int(name string) int {
+	v, _ := p[name].(int)
+	return v
+}
+
+// Float returns a float parameter, or 0 if it is not set
+func (p Params) Float(name string) float64 {
+	v, _ := p[name].(float64)
+	return v
+}
+
+// Bool returns a bool parameter, or false if it is not set
+func (p Params) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+// Duration returns a duration parameter, or 0 if it is not set
+func (p Params) Duration(name string) time.Duration {
+	v, _ := p[name].(time.Duration)
+	return v
+}
+
+type registeredTask struct {
+	fn     TaskFunc
+	schema TaskSchema
+}
+
+// TaskRegistry holds the named tasks which jobs created through the API can run
+type TaskRegistry struct {
+	mu    sync.RWMutex
+	tasks map[string]registeredTask
+}
+
+// NewTaskRegistry creates an empty task registry
+func NewTaskRegistry() *TaskRegistry {
+	return &TaskRegistry{
+		tasks: make(map[string]registeredTask),
+	}
+}
+
+// Register adds a task to the registry
+func (r *TaskRegistry) Register(name string, fn TaskFunc, schema TaskSchema) error {
+	if name == "" {
+		return errors.New("gocron-ui: task name is required")
+	}
+	if fn == nil {
+		return fmt.Errorf("gocron-ui: task %q has no function", name)
+	}
+
+	seen := make(map[string]bool, len(schema.Params))
+	for _, param := range schema.Params {
+		if param.Name == "" {
+			return fmt.Errorf("gocron-ui: task %q has a parameter without a name", name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("gocron-ui: task %q has duplicate parameter %q", name, param.Name)
+		}
+		seen[param.Name] = true
+
+		switch param.Type {
+		case ParamString, ParamInt, ParamFloat, ParamBool, ParamDuration:
+		default:
+			return fmt.Errorf("gocron-ui: task %q parameter %q has unsupported type %q", name, param.Name, param.Type)
+		}
+		if param.Default != nil {
+			if _, err := convertParam(param.Type, param.Default); err != nil {
+				return fmt.Errorf("gocron-ui: task %q parameter %q has an invalid default: %w", name, param.Name, err)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tasks[name]; ok {
+		return fmt.Errorf("gocron-ui: task %q is already registered", name)
+	}
+	r.tasks[name] = registeredTask{fn: fn, schema: schema}
+	return nil
+}
+
+// Tasks lists the registered tasks sorted by name
+func (r *TaskRegistry) Tasks() []TaskInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]TaskInfo, 0, len(r.tasks))
+	for name, task := range r.tasks {
+		info := TaskInfo{Name: name, TaskSchema: task.schema}
+		if info.Params == nil {
+			info.Params = []TaskParam{}
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// bind validates raw parameters against the schema of a task and returns the task's function with the converted parameters
+func (r *TaskRegistry) bind(name string, raw map[string]any) (TaskFunc, Params, error) {
+	r.mu.RLock()
+	task, ok := r.tasks[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, nil, invalidField("task", fmt.Sprintf("Unknown task %q", name))
+	}
+
+	errs := fieldErrors{}
+	known := make(map[string]bool, len(task.schema.Params))
+	params := make(Params, len(task.schema.Params))
+	for _, param := range task.schema.Params {
+		known[param.Name] = true
+		field := "params." + param.Name
+
+		value, ok := raw[param.Name]
+		if !ok || value == nil {
+			if param.Required {
+				errs[field] = fmt.Sprintf("Parameter %q is required", param.Name)
+				continue
+			}
+			if param.Default == nil {
+				continue
+			}
+			value = param.Default
+		}
+
+		converted, err := convertParam(param.Type, value)
+		if err != nil {
+			errs[field] = fmt.Sprintf("Parameter %q %v", param.Name, err)
+			continue
+		}
+		params[param.Name] = converted
+	}
+
+	for name := range raw {
+		if !known[name] {
+			errs["params."+name] = fmt.Sprintf("Unknown parameter %q", name)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	return task.fn, params, nil
+}
+
+// convertParam converts a decoded JSON value, or a Go value given as a default, to the Go type of a parameter
+func convertParam(typ ParamType, value any) (any, error) {
+	switch typ {
+	case ParamString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, errors.New("must be a string")
+
+	case ParamInt:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case int64:
+			return int(v), nil
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int(v), nil
+			}
+		}
+		return nil, errors.New("must be an integer")
+
+	case ParamFloat:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		}
+		return nil, errors.New("must be a number")
+
+	case ParamBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, errors.New("must be a boolean")
+
+	case ParamDuration:
+		switch v := value.(type) {
+		case time.Duration:
+			return v, nil
+		case string:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, errors.New("must be a duration such as 1m30s")
+			}
+			return d, nil
+		}
+		return nil, errors.New("must be a duration such as 1m30s")
+
+	default:
+		return nil, fmt.Errorf("has unsupported type %q", typ)
+	}
+}
+
+// WithTask registers a named task which jobs created through the API can run.
+// NewServer returns the error if the task cannot be registered.
+func WithTask(name string, fn TaskFunc, schema TaskSchema) Option {
+	return func(s *Server) {
+		if err := s.tasks.Register(name, fn, schema); err != nil {
+			s.optionErrs = append(s.optionErrs, err)
+		}
+	}
+}
+
+// Tasks returns the registry of tasks which jobs created through the API can run.
+// Tasks can be registered on it after the server was created, but jobs restored from a store
+// when the server is created can only use tasks registered with WithTask.
+func (s *Server) Tasks() *TaskRegistry {
+	return s.tasks
+}
+
+// GetTasks lists the tasks which jobs created through the API can run
+func (s *Server) GetTasks(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.tasks.Tasks())
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func TestWithTaskInvalid(t *testing.T) {
+	noop := func(context.Context, Params) error { return nil }
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"duplicate", []Option{WithTask("report", noop, TaskSchema{}), WithTask("report", noop, TaskSchema{})}, `"report"`},
+		{"no function", []Option{WithTask("report", nil, TaskSchema{})}, "has no function"},
+		{"bad parameter", []Option{WithTask("report", noop, TaskSchema{Params: []TaskParam{{Name: "n", Type: "complex"}}})}, "unsupported type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler, err := gocron.NewScheduler()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = scheduler.Shutdown() })
+
+			s, err := NewServer(scheduler, 0, tt.opts...)
+			if err == nil {
+				_ = s.Shutdown(context.Background())
+				t.Fatal("created a server with an invalid task")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.want)
+			}
+		})
+	}
+}
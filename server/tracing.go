@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans created by the server
+const tracerName = "github.com/go-co-op/gocron-ui/server"
+
+// errorType is the type of error, the last return value of a task which failed
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// span attributes of job runs
+const (
+	attrJobID      = attribute.Key("job.id")
+	attrJobName    = attribute.Key("job.name")
+	attrJobTags    = attribute.Key("job.tags")
+	attrJobTrigger = attribute.Key("job.trigger")
+)
+
+// WithTracerProvider traces job runs and API requests with OpenTelemetry. Every request handled by the router
+// gets a server span, and every run of a job a span which is linked to the span of the RunJob request that
+// triggered it. Tasks registered through the server run with the span in their context, so tasks which take a
+// context.Context can add child spans. Runs of jobs registered directly on the scheduler get their span once
+// they finished, which needs a monitor. Trace context is read from incoming requests with the global propagator.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *Server) {
+		s.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// traceRequests is a mux middleware which creates a server span for every request, named by its route.
+// WebSocket connections and event streams are long-lived and not traced.
+func (s *Server) traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStreaming(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := s.tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", s.remoteIP(r)),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, strconv.Itoa(rec.status))
+		}
+	})
+}
+
+// markTrigger remembers the span of a RunJob request, so that the run it triggers is linked to it
+func (s *Server) markTrigger(ctx context.Context, id uuid.UUID) {
+	sc := trace.SpanContextFromContext(ctx)
+	if s.tracer == nil || !sc.IsValid() {
+		return
+	}
+	s.runsMutex.Lock()
+	s.triggers[id] = append(s.triggers[id], sc)
+	s.runsMutex.Unlock()
+}
+
+// takeTrigger consumes the span of the oldest RunJob request of a job which did not start a run yet
+func (s *Server) takeTrigger(id uuid.UUID) (trace.SpanContext, bool) {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+
+	pending := s.triggers[id]
+	if len(pending) == 0 {
+		return trace.SpanContext{}, false
+	}
+	if len(pending) == 1 {
+		delete(s.triggers, id)
+	} else {
+		s.triggers[id] = pending[1:]
+	}
+	return pending[0], true
+}
+
+// startRunSpan starts the span of a job run which is about to call the job's task
+func (s *Server) startRunSpan(ctx context.Context, id uuid.UUID) (context.Context, trace.Span) {
+	name, tags := "", []string(nil)
+	if mj, ok := s.managedJob(id); ok {
+		name, tags = mj.name, mj.tags
+	}
+
+	trigger := TriggerScheduled
+	opts := []trace.SpanStartOption{}
+	if sc, ok := s.takeTrigger(id); ok {
+		trigger = TriggerManual
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+	opts = append(opts, trace.WithAttributes(
+		attrJobID.String(id.String()),
+		attrJobName.String(name),
+		attrJobTags.StringSlice(tags),
+		attrJobTrigger.String(trigger),
+	))
+	return s.tracer.Start(ctx, "job "+name, opts...)
+}
+
+// traceTask wraps the function of a task so that every call runs in a span, which ends with the error
+// the function returned or the panic it raised. Functions which take a context.Context get the span in it.
+func (s *Server) traceTask(fn reflect.Value, ref *jobRef) reflect.Value {
+	fnType := fn.Type()
+	withContext := fnType.NumIn() > 0 && fnType.In(0) == contextType
+	returnsError := fnType.NumOut() > 0 && fnType.Out(fnType.NumOut()-1) == errorType
+
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) (results []reflect.Value) {
+		id, ok := ref.get()
+		if !ok {
+			return call(fn, args)
+		}
+
+		ctx := context.Background()
+		if withContext {
+			if given, _ := args[0].Interface().(context.Context); given != nil {
+				ctx = given
+			}
+		}
+		ctx, span := s.startRunSpan(ctx, id)
+		defer func() {
+			if r := recover(); r != nil {
+				span.RecordError(fmt.Errorf("panic: %v", r))
+				span.SetStatus(codes.Error, "panic")
+				span.End()
+				panic(r)
+			}
+			if returnsError {
+				if err, _ := results[len(results)-1].Interface().(error); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				}
+			}
+			span.End()
+		}()
+
+		if withContext {
+			args[0] = reflect.ValueOf(&ctx).Elem()
+		}
+		return call(fn, args)
+	})
+}
+
+// recordRunSpan creates the span of a finished run of a job which was registered directly on the scheduler,
+// the server does not call the tasks of these jobs and only learns about their runs afterwards
+func (s *Server) recordRunSpan(run JobRun, tags []string) {
+	if s.tracer == nil {
+		return
+	}
+	id, err := uuid.Parse(run.JobID)
+	if err != nil {
+		return
+	}
+	if _, ok := s.managedJob(id); ok {
+		// the span was created while the task ran
+		return
+	}
+
+	opts := []trace.SpanStartOption{trace.WithTimestamp(run.StartedAt)}
+	if run.Trigger == TriggerManual {
+		if sc, ok := s.takeTrigger(id); ok {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+	opts = append(opts, trace.WithAttributes(
+		attrJobID.String(run.JobID),
+		attrJobName.String(run.JobName),
+		attrJobTags.StringSlice(tags),
+		attrJobTrigger.String(run.Trigger),
+	))
+	_, span := s.tracer.Start(context.Background(), "job "+run.JobName, opts...)
+	if run.Error != "" {
+		span.SetStatus(codes.Error, run.Error)
+	}
+	span.End(trace.WithTimestamp(run.FinishedAt))
+}
+
+// call calls a function with the given arguments, which may end with the values of a variadic parameter
+func call(fn reflect.Value, args []reflect.Value) []reflect.Value {
+	if fn.Type().IsVariadic() {
+		return fn.CallSlice(args)
+	}
+	return fn.Call(args)
+}
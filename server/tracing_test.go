@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedServer creates a server on a started scheduler whose spans end up in the returned exporter
+func newTracedServer(t *testing.T, opts ...Option) (*Server, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+
+	s, err := NewServer(scheduler, 0, append([]Option{WithTracerProvider(tp)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	return s, exporter
+}
+
+// waitForSpan returns the first ended span with the name, failing the test if none ends in time
+func waitForSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, span := range exporter.GetSpans() {
+			if span.Name == name {
+				return span
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no span %q, got %v", name, spanNames(exporter.GetSpans()))
+	return tracetest.SpanStub{}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTraceRequests(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	s, exporter := newTracedServer(t)
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+uuid.NewString(), nil)
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), parent), propagation.HeaderCarrier(req.Header))
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	span := waitForSpan(t, exporter, "GET /api/jobs/{id}")
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("kind = %v, want server", span.SpanKind)
+	}
+	if span.Parent.SpanID() != parent.SpanID() || span.SpanContext.TraceID() != parent.TraceID() {
+		t.Errorf("span is not a child of the incoming trace context")
+	}
+	if got := spanAttr(span, "http.route").AsString(); got != "/api/jobs/{id}" {
+		t.Errorf("http.route = %q", got)
+	}
+	if got := spanAttr(span, "http.response.status_code").AsInt64(); got != http.StatusNotFound {
+		t.Errorf("http.response.status_code = %d", got)
+	}
+	if span.Status.Code == codes.Error {
+		t.Errorf("a 404 marked the span as failed")
+	}
+}
+
+func TestTraceRequestsRejectedByAuth(t *testing.T) {
+	s, exporter := newTracedServer(t, WithAuthenticator(NewBasicAuthenticator("", Users{{Name: "alice", Password: "secret"}})))
+
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	span := waitForSpan(t, exporter, "GET /api/jobs")
+	if got := spanAttr(span, "http.response.status_code").AsInt64(); got != http.StatusUnauthorized {
+		t.Errorf("http.response.status_code = %d", got)
+	}
+}
+
+func TestTraceManualRun(t *testing.T) {
+	s, exporter := newTracedServer(t)
+
+	type call struct {
+		span trace.SpanContext
+		n    int
+	}
+	calls := make(chan call, 1)
+	job, err := s.NewJob(DurationJob(time.Hour), NewTask(func(ctx context.Context, n int) error {
+		calls <- call{span: trace.SpanContextFromContext(ctx), n: n}
+		return errors.New("disk full")
+	}, 7), gocron.WithName("cleanup"), gocron.WithTags("ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.ID().String()+"/run", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var got call
+	select {
+	case got = <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not run")
+	}
+	if got.n != 7 {
+		t.Errorf("task got %d, want its parameter 7", got.n)
+	}
+
+	request := waitForSpan(t, exporter, "POST /api/jobs/{id}/run")
+	run := waitForSpan(t, exporter, "job cleanup")
+	if !got.span.Equal(run.SpanContext) {
+		t.Errorf("task ran with span %v, want the run's span %v", got.span.SpanID(), run.SpanContext.SpanID())
+	}
+	if len(run.Links) != 1 || run.Links[0].SpanContext.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("run is not linked to the RunJob request: %+v", run.Links)
+	}
+	if got := spanAttr(run, attrJobTrigger).AsString(); got != TriggerManual {
+		t.Errorf("trigger = %q, want %q", got, TriggerManual)
+	}
+	if got := spanAttr(run, attrJobID).AsString(); got != job.ID().String() {
+		t.Errorf("job.id = %q", got)
+	}
+	if got := spanAttr(run, attrJobTags).AsStringSlice(); len(got) != 1 || got[0] != "ops" {
+		t.Errorf("job.tags = %v", got)
+	}
+	if run.Status.Code != codes.Error || run.Status.Description != "disk full" {
+		t.Errorf("status = %+v, want the task's error", run.Status)
+	}
+	if _, pending := s.takeTrigger(job.ID()); pending {
+		t.Errorf("the trigger was not consumed by the run")
+	}
+}
+
+func TestTraceScheduledRun(t *testing.T) {
+	s, exporter := newTracedServer(t)
+
+	done := make(chan struct{})
+	_, err := s.NewJob(DurationJob(time.Hour), NewTask(func() { close(done) }),
+		gocron.WithName("report"), gocron.WithStartAt(gocron.WithStartImmediately()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not run")
+	}
+
+	run := waitForSpan(t, exporter, "job report")
+	if got := spanAttr(run, attrJobTrigger).AsString(); got != TriggerScheduled {
+		t.Errorf("trigger = %q, want %q", got, TriggerScheduled)
+	}
+	if len(run.Links) != 0 {
+		t.Errorf("a scheduled run has links: %+v", run.Links)
+	}
+	if run.Status.Code == codes.Error {
+		t.Errorf("status = %+v", run.Status)
+	}
+}
+
+func TestRecordRunSpan(t *testing.T) {
+	s, exporter := newTracedServer(t)
+
+	// a job registered directly on the scheduler, which the server only learns about from the monitor
+	id := uuid.New()
+	trigger := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{3},
+		SpanID:     trace.SpanID{4},
+		TraceFlags: trace.FlagsSampled,
+	})
+	s.markTrigger(trace.ContextWithSpanContext(context.Background(), trigger), id)
+
+	started := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	finished := started.Add(30 * time.Second)
+	s.recordRunSpan(JobRun{
+		JobID:      id.String(),
+		JobName:    "backup",
+		StartedAt:  started,
+		FinishedAt: finished,
+		Status:     RunStatusFailed,
+		Error:      "timeout",
+		Trigger:    TriggerManual,
+	}, []string{"nightly"})
+
+	run := waitForSpan(t, exporter, "job backup")
+	if !run.StartTime.Equal(started) || !run.EndTime.Equal(finished) {
+		t.Errorf("span from %v to %v, want %v to %v", run.StartTime, run.EndTime, started, finished)
+	}
+	if len(run.Links) != 1 || run.Links[0].SpanContext.SpanID() != trigger.SpanID() {
+		t.Errorf("run is not linked to the trigger: %+v", run.Links)
+	}
+	if run.Status.Code != codes.Error || run.Status.Description != "timeout" {
+		t.Errorf("status = %+v", run.Status)
+	}
+	if got := spanAttr(run, attrJobTags).AsStringSlice(); len(got) != 1 || got[0] != "nightly" {
+		t.Errorf("job.tags = %v", got)
+	}
+
+	// jobs registered through the server got their span while they ran
+	exporter.Reset()
+	job, err := s.NewJob(DurationJob(time.Hour), NewTask(func() {}), gocron.WithName("managed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.recordRunSpan(JobRun{JobID: job.ID().String(), JobName: "managed", StartedAt: started, FinishedAt: finished}, nil)
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("managed job got a second span: %v", spanNames(spans))
+	}
+}
@@ -1,23 +1,141 @@
 package server
 
+import (
+	"context"
+	"time"
+)
+
 // JobData represents the job information sent to clients
 type JobData struct {
-	ID             string   `json:"id"`
-	Name           string   `json:"name"`
-	Tags           []string `json:"tags"`
-	NextRun        string   `json:"nextRun"`
-	LastRun        string   `json:"lastRun"`
-	NextRuns       []string `json:"nextRuns"`
-	Schedule       string   `json:"schedule"`       // human-readable schedule description
-	ScheduleDetail string   `json:"scheduleDetail"` // technical schedule details (cron expression, interval, etc.)
+	ID             string        `json:"id"`
+	Name           string        `json:"name"`
+	Tags           []string      `json:"tags"`
+	NextRun        string        `json:"nextRun"`
+	LastRun        string        `json:"lastRun"`
+	NextRuns       []string      `json:"nextRuns"`
+	Schedule       string        `json:"schedule"`               // human-readable schedule description
+	ScheduleDetail string        `json:"scheduleDetail"`         // technical schedule details (cron expression, interval, etc.)
+	ScheduleSpec   *ScheduleSpec `json:"scheduleSpec,omitempty"` // only known for jobs registered through the server
+	Options        *JobOptions   `json:"options,omitempty"`      // only known for jobs created through the API
+	Running        bool          `json:"running"`
+	RunningSince   string        `json:"runningSince,omitempty"` // start of the oldest execution in progress
+	RunningCount   int           `json:"runningCount"`           // executions in progress, more than one without singleton mode
+	ActiveRuns     []ActiveRun   `json:"activeRuns,omitempty"`
+	Paused         bool          `json:"paused"`
+	PauseReason    string        `json:"pauseReason,omitempty"`
+	PausedBy       string        `json:"pausedBy,omitempty"`
+	PausedAt       string        `json:"pausedAt,omitempty"`
+	// ConsecutiveFailures is how many of the latest runs failed in a row, only known with a monitor
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	Permissions         []Permission `json:"permissions,omitempty"` // what the caller may do with the job, only set with WithAuthorization
 }
 
 // CreateJobRequest represents the request to create a new job
 type CreateJobRequest struct {
-	Name           string   `json:"name"`
-	Type           string   `json:"type"` // duration, cron, daily, weekly, monthly
-	Interval       int64    `json:"interval,omitempty"`
-	CronExpression string   `json:"cronExpression,omitempty"`
-	AtTime         string   `json:"atTime,omitempty"` // Format: HH:MM:SS
-	Tags           []string `json:"tags,omitempty"`
+	Name           string         `json:"name"`
+	Type           string         `json:"type"`                  // duration, random, cron, daily, weekly, monthly, onetime
+	Interval       int64          `json:"interval,omitempty"`    // seconds for duration jobs, days, weeks or months otherwise
+	MinInterval    int64          `json:"minInterval,omitempty"` // random jobs, seconds
+	MaxInterval    int64          `json:"maxInterval,omitempty"` // random jobs, seconds
+	CronExpression string         `json:"cronExpression,omitempty"`
+	AtTime         string         `json:"atTime,omitempty"`      // Format: HH:MM:SS, a single time of day, see AtTimes
+	AtTimes        []string       `json:"atTimes,omitempty"`     // daily, weekly and monthly jobs, HH:MM:SS
+	Weekdays       []string       `json:"weekdays,omitempty"`    // weekly jobs, e.g. "Monday" or "mon"
+	DaysOfMonth    []int          `json:"daysOfMonth,omitempty"` // monthly jobs, 1 to 31 or -1 to -31 counting from the end of the month
+	Times          []string       `json:"times,omitempty"`       // one-time jobs, RFC3339
+	Tags           []string       `json:"tags,omitempty"`
+	Task           string         `json:"task,omitempty"`    // name of a task registered with WithTask
+	Params         map[string]any `json:"params,omitempty"`  // task parameters, validated against the task's schema
+	Options        *JobOptions    `json:"options,omitempty"` // gocron job options
+}
+
+// JobOptions are the gocron job options which can be set through the API
+type JobOptions struct {
+	SingletonMode    string `json:"singletonMode,omitempty"`    // wait, reschedule; see gocron.WithSingletonMode
+	LimitedRuns      uint   `json:"limitedRuns,omitempty"`      // the job is removed after this many runs
+	StartImmediately bool   `json:"startImmediately,omitempty"` // run once as soon as the job is added
+	StartAt          string `json:"startAt,omitempty"`          // RFC3339, first run not before this time
+	StopAt           string `json:"stopAt,omitempty"`           // RFC3339, the job is removed at this time
+	Identifier       string `json:"identifier,omitempty"`       // UUID to create the job with
+}
+
+// PauseJobRequest represents the optional body of a request to pause a job
+type PauseJobRequest struct {
+	Reason   string `json:"reason,omitempty"`
+	PausedBy string `json:"pausedBy,omitempty"`
+}
+
+// PauseInfo describes why, when and by whom a job was paused
+type PauseInfo struct {
+	Reason   string    `json:"reason,omitempty"`
+	PausedBy string    `json:"pausedBy,omitempty"`
+	PausedAt time.Time `json:"pausedAt"`
+}
+
+// JobRun represents a single recorded execution of a job
+type JobRun struct {
+	ID         string    `json:"id"`
+	JobID      string    `json:"jobId"`
+	JobName    string    `json:"jobName"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Status     string    `json:"status"` // success, failed, cancelled
+	Error      string    `json:"error,omitempty"`
+	Panicked   bool      `json:"panicked"`
+	Trigger    string    `json:"trigger"` // scheduled, manual
+}
+
+// ActiveRun is an execution of a job which is in progress
+type ActiveRun struct {
+	ID        string    `json:"id"` // the ID of the run in the history once it finished
+	JobID     string    `json:"jobId"`
+	JobName   string    `json:"jobName"`
+	StartedAt time.Time `json:"startedAt"`
+	Trigger   string    `json:"trigger"` // scheduled, manual
+	// Cancellable is set once a task which takes a context.Context started, see POST /api/jobs/{id}/runs/{runId}/cancel
+	Cancellable bool `json:"cancellable"`
+
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// JobRunsResponse is a page of a job's run history
+type JobRunsResponse struct {
+	Runs   []JobRun `json:"runs"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
+
+// AuditEntry records a call of a mutating endpoint
+type AuditEntry struct {
+	ID       string        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Actor    string        `json:"actor"` // the authenticated caller, empty without authentication
+	IP       string        `json:"ip"`
+	Action   string        `json:"action"`
+	Endpoint string        `json:"endpoint"` // method and route, e.g. DELETE /api/jobs/{id}
+	JobID    string        `json:"jobId,omitempty"`
+	JobName  string        `json:"jobName,omitempty"`
+	RuleID   string        `json:"ruleId,omitempty"`  // the alert rule of alert operations
+	Changes  []AuditChange `json:"changes,omitempty"` // how the job's definition changed
+	Status   int           `json:"status"`
+	Outcome  string        `json:"outcome"` // success, failure, denied
+	Error    string        `json:"error,omitempty"`
+}
+
+// AuditChange is a field of a job's definition which an operation changed
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditResponse is a page of the audit log
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
 }
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// errNoRunsLeft is returned when a job which used up its runs would be registered again
+var errNoRunsLeft = errors.New("gocron-ui: job has no runs left")
+
+// fieldErrors describes an invalid request by mapping each invalid field to what is wrong with it
+type fieldErrors map[string]string
+
+func invalidField(field, message string) fieldErrors {
+	return fieldErrors{field: message}
+}
+
+func (e fieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, e[field])
+	}
+	return strings.Join(messages, "; ")
+}
+
+// merge adds the fields of another error if it describes invalid fields, any other error is returned
+func (e fieldErrors) merge(err error) error {
+	var other fieldErrors
+	if !errors.As(err, &other) {
+		return err
+	}
+	for field, message := range other {
+		e[field] = message
+	}
+	return nil
+}
+
+// errOrNil returns nil instead of an empty error
+func (e fieldErrors) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// schedulerError turns the errors gocron returns for invalid job definitions into field errors
+func schedulerError(err error) error {
+	if errors.Is(err, gocron.ErrCronJobParse) || errors.Is(err, gocron.ErrCronJobInvalid) {
+		return invalidField("cronExpression", "Invalid cron expression: "+err.Error())
+	}
+	return err
+}
+
+// respondJobError responds with the field-level detail of an invalid job request, with a conflict for a job
+// without runs left, or with an internal error otherwise
+func respondJobError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNoRunsLeft) {
+		respondError(w, http.StatusConflict, "Job has no runs left")
+		return
+	}
+	var fields fieldErrors
+	if errors.As(err, &fields) {
+		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  fields.Error(),
+			"fields": fields,
+		})
+		return
+	}
+	respondError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait is how long a client may take to accept a message
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long a client may stay silent, browsers answer pings automatically
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod is how often clients are pinged, it has to be shorter than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxMessageSize limits what clients may send
+	wsMaxMessageSize = 4096
+)
+
+// wsClient writes the updates of a subscriber to a WebSocket connection from its own goroutine
+type wsClient struct {
+	*subscriber
+	conn *websocket.Conn
+}
+
+// writeLoop writes the queued messages and pings until the client is closed or a write fails
+func (c *wsClient) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case messages := <-c.send:
+			for _, message := range messages {
+				_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if err := c.conn.WriteJSON(message); err != nil {
+					log.Printf("Error writing to WebSocket client: %v", err)
+					return
+				}
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.closing:
+			message := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+			_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+			return
+		}
+	}
+}
+
+// readLoop reads until the connection fails or the peer stops answering pings. Clients have nothing to say,
+// reading is how the close frame and the pongs of the peer are processed.
+func (c *wsClient) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// HandleWebSocket is a webSocket handler. Clients of protocol version 2 get a snapshot of the jobs and then
+// only the events which change them, or the events they missed if they resume with ?stream=&since=.
+// With the query parameters of JobFilter a client only gets the jobs which match.
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseJobFilter(r.URL.Query())
+	if err != nil {
+		respondJobError(w, err)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+
+	p, _ := PrincipalFromContext(r.Context())
+	query := r.URL.Query()
+	version := 1
+	if v, err := strconv.Atoi(query.Get("v")); err == nil && v >= ProtocolVersion {
+		version = ProtocolVersion
+	}
+	client := &wsClient{subscriber: newSubscriber(transportWebSocket, p, version, filter), conn: conn}
+	go client.writeLoop()
+
+	var resume *eventPosition
+	if since, err := strconv.ParseUint(query.Get("since"), 10, 64); err == nil {
+		resume = &eventPosition{stream: query.Get("stream"), seq: since}
+	}
+	total := s.subscribe(client.subscriber, resume)
+	log.Printf("WebSocket client connected. Total clients: %d", total)
+
+	client.readLoop()
+
+	total = s.unsubscribe(client.subscriber)
+	client.close(websocket.CloseNormalClosure, "")
+	log.Printf("WebSocket client disconnected. Total clients: %d", total)
+}